@@ -0,0 +1,141 @@
+package consistency
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ducksify/panop-tools/dkimizator/internal/crypto"
+	"github.com/ducksify/panop-tools/dkimizator/internal/dkim"
+	"github.com/ducksify/panop-tools/dkimizator/internal/dns"
+)
+
+// Answer is what a single server returned for a selector
+type Answer struct {
+	Server        string   `json:"server"`
+	Address       string   `json:"address"`
+	Authoritative bool     `json:"authoritative"`
+	Status        string   `json:"status"`
+	TXT           []string `json:"txt,omitempty"`
+	Fingerprint   string   `json:"fingerprint,omitempty"`
+}
+
+// SelectorCheck compares the answers of all servers for one selector
+type SelectorCheck struct {
+	Selector   string   `json:"selector"`
+	FQDN       string   `json:"fqdn"`
+	Consistent bool     `json:"consistent"`
+	Answers    []Answer `json:"answers"`
+	// Disagreements describes every difference found between answers
+	Disagreements []string `json:"disagreements,omitempty"`
+}
+
+// Check queries every selector on every server and reports where answers
+// differ in status, TXT content or key fingerprint
+func Check(ctx context.Context, domain string, selectors []string, servers []dns.Server, timeout time.Duration) []SelectorCheck {
+	checks := make([]SelectorCheck, 0, len(selectors))
+
+	for _, selector := range selectors {
+		check := SelectorCheck{
+			Selector: selector,
+			FQDN:     selector + "._domainkey." + domain,
+		}
+
+		results, err := dns.QueryServers(ctx, selector, domain, servers, timeout)
+		if err != nil {
+			slog.Warn("consistency check failed", "selector", selector, "error", err)
+			continue
+		}
+
+		for i, result := range results {
+			check.Answers = append(check.Answers, Answer{
+				Server:        servers[i].Name,
+				Address:       servers[i].Address,
+				Authoritative: servers[i].Authoritative,
+				Status:        result.Status,
+				TXT:           result.TXT,
+				Fingerprint:   fingerprint(result.TXT),
+			})
+		}
+
+		check.Disagreements = compare(check.Answers)
+		check.Consistent = len(check.Disagreements) == 0
+		checks = append(checks, check)
+	}
+
+	return checks
+}
+
+// compare groups answers by status, TXT content and fingerprint and
+// describes every group that is not shared by all servers
+func compare(answers []Answer) []string {
+	var disagreements []string
+
+	fields := []struct {
+		name  string
+		value func(Answer) string
+	}{
+		{"status", func(a Answer) string { return a.Status }},
+		{"txt", func(a Answer) string { return normalizeTXT(a.TXT) }},
+		{"fingerprint", func(a Answer) string { return a.Fingerprint }},
+	}
+
+	for _, field := range fields {
+		groups := make(map[string][]string)
+		for _, answer := range answers {
+			value := field.value(answer)
+			groups[value] = append(groups[value], answer.Server)
+		}
+		if len(groups) < 2 {
+			continue
+		}
+
+		values := make([]string, 0, len(groups))
+		for value := range groups {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+		for _, value := range values {
+			label := value
+			if label == "" {
+				label = "(none)"
+			}
+			disagreements = append(disagreements, field.name+" "+label+" from "+strings.Join(groups[value], ", "))
+		}
+	}
+
+	return disagreements
+}
+
+// normalizeTXT removes the whitespace of every TXT record, which servers may
+// fold differently without changing the record, and sorts the records, as
+// the order of an RRset is not significant
+func normalizeTXT(txt []string) string {
+	records := make([]string, len(txt))
+	for i, record := range txt {
+		records[i] = strings.Join(strings.Fields(record), "")
+	}
+	sort.Strings(records)
+	return strings.Join(records, " ")
+}
+
+// fingerprint returns the key fingerprints of the DKIM TXT records, sorted
+// like normalizeTXT sorts the records
+func fingerprint(txt []string) string {
+	var fingerprints []string
+	for _, txt := range txt {
+		record, err := dkim.ParseTXT([]string{txt})
+		if err != nil || record.PublicKey == "" {
+			continue
+		}
+		keyInfo, err := crypto.AnalyzeKey(record)
+		if err != nil {
+			continue
+		}
+		fingerprints = append(fingerprints, keyInfo.Fingerprint)
+	}
+	sort.Strings(fingerprints)
+	return strings.Join(fingerprints, " ")
+}
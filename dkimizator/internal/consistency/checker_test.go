@@ -0,0 +1,91 @@
+package consistency
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name     string
+		answers  []Answer
+		expected []string
+	}{
+		{
+			name: "identical answers",
+			answers: []Answer{
+				{Server: "ns1", Status: "NOERROR", TXT: []string{"v=DKIM1; p=AAAA"}, Fingerprint: "aa"},
+				{Server: "ns2", Status: "NOERROR", TXT: []string{"v=DKIM1;  p=AAAA "}, Fingerprint: "aa"},
+			},
+			expected: nil,
+		},
+		{
+			name: "same records in another order",
+			answers: []Answer{
+				{Server: "ns1", Status: "NOERROR", TXT: []string{"v=DKIM1; p=AAAA", "v=DKIM1; p=BBBB"}, Fingerprint: "aa"},
+				{Server: "ns2", Status: "NOERROR", TXT: []string{"v=DKIM1; p=BBBB", "v=DKIM1; p=AAAA"}, Fingerprint: "aa"},
+			},
+			expected: nil,
+		},
+		{
+			name: "one record missing from the set",
+			answers: []Answer{
+				{Server: "ns1", Status: "NOERROR", TXT: []string{"p=AAAA", "p=BBBB"}, Fingerprint: "aa"},
+				{Server: "ns2", Status: "NOERROR", TXT: []string{"p=AAAA"}, Fingerprint: "aa"},
+			},
+			expected: []string{"txt p=AAAA from ns2", "txt p=AAAA p=BBBB from ns1"},
+		},
+		{
+			name: "different key",
+			answers: []Answer{
+				{Server: "ns1", Status: "NOERROR", TXT: []string{"p=AAAA"}, Fingerprint: "aa"},
+				{Server: "ns2", Status: "NOERROR", TXT: []string{"p=BBBB"}, Fingerprint: "bb"},
+			},
+			expected: []string{"txt p=AAAA from ns1", "txt p=BBBB from ns2", "fingerprint aa from ns1", "fingerprint bb from ns2"},
+		},
+		{
+			name: "missing on one server",
+			answers: []Answer{
+				{Server: "ns1", Status: "NOERROR", TXT: []string{"p=AAAA"}, Fingerprint: "aa"},
+				{Server: "ns2", Status: "NXDOMAIN"},
+			},
+			expected: []string{"status NOERROR from ns1", "status NXDOMAIN from ns2", "txt (none) from ns2", "txt p=AAAA from ns1", "fingerprint (none) from ns2", "fingerprint aa from ns1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compare(tt.answers)
+			if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("compare() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFingerprintOrder(t *testing.T) {
+	var records []string
+	for i := 0; i < 2; i++ {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, "v=DKIM1; k=rsa; p="+base64.StdEncoding.EncodeToString(der))
+	}
+
+	got := fingerprint(records)
+	if strings.Count(got, " ") != 1 {
+		t.Fatalf("fingerprint() = %q, want two fingerprints", got)
+	}
+	if reversed := fingerprint([]string{records[1], records[0]}); reversed != got {
+		t.Errorf("fingerprint() depends on record order: %q != %q", reversed, got)
+	}
+}
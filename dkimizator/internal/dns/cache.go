@@ -0,0 +1,51 @@
+package dns
+
+import (
+	"github.com/zmap/zdns/v2/src/zdns"
+)
+
+// DefaultCacheSize is the number of entries kept by the shared resolution cache
+const DefaultCacheSize = 100000
+
+// rootNameServers overrides the root servers used for iterative resolution,
+// the zdns defaults when empty
+var rootNameServers []zdns.NameServer
+
+// Cache holds the delegations, name server addresses and answers learned by
+// iterative resolution. Entries expire with their TTL. A Cache is safe for
+// concurrent use and is meant to be shared by every worker and every domain
+// of a run, so the walk from the root is only done once per zone.
+type Cache struct {
+	cache *zdns.Cache
+}
+
+// CacheStats counts the cache operations since the cache was created
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Writes uint64
+	Ejects uint64
+}
+
+// NewCache creates a cache holding at most size entries, DefaultCacheSize when zero
+func NewCache(size int) *Cache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+
+	cache := new(zdns.Cache)
+	cache.Init(size)
+	cache.Stats.CaptureStatistics()
+	return &Cache{cache: cache}
+}
+
+// Stats returns the cache counters
+func (c *Cache) Stats() CacheStats {
+	stats := c.cache.Stats.GetStatistics()
+	return CacheStats{
+		Hits:   stats.Hits,
+		Misses: stats.Misses,
+		Writes: stats.Writes,
+		Ejects: stats.Ejects,
+	}
+}
@@ -0,0 +1,150 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zmap/dns"
+	"github.com/zmap/zdns/v2/src/zdns"
+)
+
+// fakeServer is one level of a local DNS hierarchy counting the queries it answers
+type fakeServer struct {
+	server  *dns.Server
+	queries atomic.Int64
+}
+
+// fakeServerDelay is added to every answer, as a stand-in for the network
+// round trip that loopback lacks, so wall-clock time reflects query volume
+const fakeServerDelay = 5 * time.Millisecond
+
+// startFakeServer serves handle on addr:53 over UDP after fakeServerDelay.
+// Referrals always point to port 53, so every level needs its own loopback
+// address.
+func startFakeServer(b *testing.B, addr string, handle func(req, resp *dns.Msg)) *fakeServer {
+	b.Helper()
+
+	conn, err := net.ListenPacket("udp", net.JoinHostPort(addr, "53"))
+	if err != nil {
+		b.Skipf("cannot listen on %s:53: %v", addr, err)
+	}
+
+	fake := &fakeServer{}
+	started := make(chan struct{})
+	fake.server = &dns.Server{
+		PacketConn:        conn,
+		NotifyStartedFunc: func() { close(started) },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			fake.queries.Add(1)
+			resp := new(dns.Msg)
+			resp.SetReply(req)
+			handle(req, resp)
+			time.Sleep(fakeServerDelay)
+			w.WriteMsg(resp)
+		}),
+	}
+	go fake.server.ActivateAndServe()
+	<-started
+	b.Cleanup(func() { fake.server.Shutdown() })
+	return fake
+}
+
+// mustRR parses a resource record in zone file syntax
+func mustRR(s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		panic(err)
+	}
+	return rr
+}
+
+// fakeHierarchy starts a root, a "test." TLD and an authoritative server for
+// every domN.test, where only the selector "s1" exists
+func fakeHierarchy(b *testing.B) (root, tld, auth *fakeServer) {
+	const rootAddr, tldAddr, authAddr = "127.0.0.2", "127.0.0.3", "127.0.0.4"
+
+	root = startFakeServer(b, rootAddr, func(req, resp *dns.Msg) {
+		resp.Ns = append(resp.Ns, mustRR("test. 86400 IN NS ns.test."))
+		resp.Extra = append(resp.Extra, mustRR("ns.test. 86400 IN A "+tldAddr))
+	})
+	tld = startFakeServer(b, tldAddr, func(req, resp *dns.Msg) {
+		labels := dns.SplitDomainName(req.Question[0].Name)
+		zone := strings.Join(labels[len(labels)-2:], ".") + "."
+		resp.Ns = append(resp.Ns, mustRR(zone+" 3600 IN NS ns."+zone))
+		resp.Extra = append(resp.Extra, mustRR("ns."+zone+" 3600 IN A "+authAddr))
+	})
+	auth = startFakeServer(b, authAddr, func(req, resp *dns.Msg) {
+		resp.Authoritative = true
+		name := req.Question[0].Name
+		labels := dns.SplitDomainName(name)
+		zone := strings.Join(labels[len(labels)-2:], ".") + "."
+		if strings.HasPrefix(name, "s1._domainkey.") && req.Question[0].Qtype == dns.TypeTXT {
+			resp.Answer = append(resp.Answer, mustRR(name+` 300 IN TXT "v=DKIM1; k=rsa; p=MIIB"`))
+			return
+		}
+		resp.Rcode = dns.RcodeNameError
+		resp.Ns = append(resp.Ns, mustRR(zone+" 300 IN SOA ns."+zone+" hostmaster."+zone+" 1 3600 600 86400 300"))
+	})
+
+	rootNameServers = []zdns.NameServer{{IP: net.ParseIP(rootAddr), Port: 53}}
+	b.Cleanup(func() { rootNameServers = nil })
+	return root, tld, auth
+}
+
+// BenchmarkQuerySelectorsCache scans many domains under one TLD of a local
+// hierarchy with a cache per QuerySelectors call and with one cache for the
+// whole run. The workers of a call share a cache either way, so the run-wide
+// cache only saves the root referral every worker asks for again per domain:
+// the TLD and authoritative queries are the same in both cases.
+func BenchmarkQuerySelectorsCache(b *testing.B) {
+	root, tld, auth := fakeHierarchy(b)
+
+	const domains = 20
+	selectors := []string{"s1", "s2", "s3", "s4", "s5", "s6", "s7", "s8"}
+
+	for _, shared := range []bool{false, true} {
+		name := "per-call"
+		if shared {
+			name = "shared"
+		}
+		b.Run(name, func(b *testing.B) {
+			root.queries.Store(0)
+			tld.queries.Store(0)
+			auth.queries.Store(0)
+
+			for i := 0; i < b.N; i++ {
+				// A new cache per iteration stands for a new run
+				var cache *Cache
+				if shared {
+					cache = NewCache(0)
+				}
+				for d := 0; d < domains; d++ {
+					domain := fmt.Sprintf("dom%d-%d.test", i, d)
+					opts := Options{Timeout: 10 * time.Second, Workers: 4, Cache: cache}
+					found := 0
+					for result := range QuerySelectors(context.Background(), selectors, domain, opts) {
+						if result.Error != nil && result.Status != StatusNXDomain {
+							b.Fatalf("%s: %v", result.FQDN, result.Error)
+						}
+						if result.Found {
+							found++
+						}
+					}
+					if found != 1 {
+						b.Fatalf("%s: found %d selectors, want 1", domain, found)
+					}
+				}
+			}
+
+			total := root.queries.Load() + tld.queries.Load() + auth.queries.Load()
+			b.ReportMetric(float64(root.queries.Load())/float64(b.N), "root-queries/op")
+			b.ReportMetric(float64(tld.queries.Load())/float64(b.N), "tld-queries/op")
+			b.ReportMetric(float64(total)/float64(b.N), "queries/op")
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zmap/zdns/v2/src/zdns"
+)
+
+// NamespaceResult is the outcome of the pre-flight query for _domainkey.<domain>
+type NamespaceResult struct {
+	FQDN   string
+	Status string
+	// Authoritative is true when the answer came from the zone's own servers,
+	// which is always the case for iterative resolution
+	Authoritative bool
+}
+
+// Missing reports whether the _domainkey node provably does not exist. Per
+// RFC 8020 an authoritative NXDOMAIN means nothing below it exists either.
+func (n *NamespaceResult) Missing() bool {
+	return n.Status == StatusNXDomain && n.Authoritative
+}
+
+// CheckNamespace queries _domainkey.<domain> before a scan. Empty non-terminal
+// nodes answer NOERROR/NODATA, so only NXDOMAIN is conclusive.
+func CheckNamespace(ctx context.Context, domain string, opts Options) (*NamespaceResult, error) {
+	nameServers, err := ParseNameServers(opts.NameServers)
+	if err != nil {
+		return nil, err
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	resolver, err := zdns.InitResolver(newResolverConfig(nameServers, opts.Cache))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize zdns resolver: %w", err)
+	}
+	defer resolver.Close()
+
+	var nameServer *zdns.NameServer
+	if len(nameServers) > 0 {
+		nameServer = &nameServers[0]
+	}
+
+	fqdn := "_domainkey." + domain
+	result := lookupTXT(queryCtx, resolver, nameServer, "", fqdn)
+
+	return &NamespaceResult{
+		FQDN:   fqdn,
+		Status: result.Status,
+		// Recursive resolvers do not expose whether the NXDOMAIN they relay
+		// was authoritative, so only iterative answers are trusted
+		Authoritative: nameServer == nil,
+	}, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -12,6 +14,19 @@ import (
 	"github.com/zmap/zdns/v2/src/zdns"
 )
 
+// Query status values reported in QueryResult.Status
+const (
+	StatusNoError  = "NOERROR"
+	StatusNXDomain = "NXDOMAIN"
+	StatusServFail = "SERVFAIL"
+	StatusRefused  = "REFUSED"
+	StatusTimeout  = "TIMEOUT"
+	StatusError    = "ERROR"
+)
+
+// DefaultWorkers is the number of concurrent resolvers (similar to zdns default of 100 threads)
+const DefaultWorkers = 100
+
 // QueryResult represents the result of a DNS query
 type QueryResult struct {
 	Selector string
@@ -19,10 +34,75 @@ type QueryResult struct {
 	TXT      []string
 	Error    error
 	Found    bool
+	Status   string
+}
+
+// Options controls how selectors are queried
+type Options struct {
+	// Timeout bounds the whole set of queries
+	Timeout time.Duration
+	// Workers is the number of concurrent resolvers, DefaultWorkers when zero
+	Workers int
+	// NameServers are recursive resolvers ("ip" or "ip:port") to query instead
+	// of resolving iteratively from the root
+	NameServers []string
+	// Cache is shared with other lookups, each call gets its own cache when nil
+	Cache *Cache
+}
+
+// ParseNameServers parses "ip" or "ip:port" strings, defaulting to port 53
+func ParseNameServers(addrs []string) ([]zdns.NameServer, error) {
+	nameServers := make([]zdns.NameServer, 0, len(addrs))
+	for _, addr := range addrs {
+		host, port := addr, "53"
+		if h, p, err := net.SplitHostPort(addr); err == nil {
+			host, port = h, p
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid name server address: %s", addr)
+		}
+		portNum, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name server port: %s", addr)
+		}
+		nameServers = append(nameServers, zdns.NameServer{IP: ip, Port: uint16(portNum)})
+	}
+	return nameServers, nil
+}
+
+// newResolverConfig creates the zdns resolver configuration shared by all workers
+func newResolverConfig(nameServers []zdns.NameServer, cache *Cache) *zdns.ResolverConfig {
+	config := zdns.NewResolverConfig()
+	// Use per-query timeout (zdns default is 15 seconds)
+	// The overall operation timeout is handled by the caller's context
+	config.Timeout = 15 * time.Second
+	config.IterativeTimeout = 8 * time.Second // Default iterative timeout
+	config.NetworkTimeout = 2 * time.Second   // Default network timeout
+	config.Retries = 3                        // Default retries
+	config.MaxDepth = 10                      // Default max depth
+
+	if cache != nil {
+		config.Cache = cache.cache
+	}
+	if len(rootNameServers) > 0 {
+		config.RootNameServersV4 = rootNameServers
+	}
+
+	for _, ns := range nameServers {
+		if ns.IP.To4() != nil {
+			config.ExternalNameServersV4 = append(config.ExternalNameServersV4, ns)
+		} else {
+			config.ExternalNameServersV6 = append(config.ExternalNameServersV6, ns)
+		}
+	}
+
+	return config
 }
 
-// QuerySelectors queries DNS for multiple selectors using zdns library with iterative resolution
-func QuerySelectors(ctx context.Context, selectors []string, domain string, timeout time.Duration) <-chan *QueryResult {
+// QuerySelectors queries DNS for multiple selectors using zdns library with iterative resolution,
+// or through opts.NameServers when set
+func QuerySelectors(ctx context.Context, selectors []string, domain string, opts Options) <-chan *QueryResult {
 	resultChan := make(chan *QueryResult, len(selectors))
 
 	// Build FQDNs and create mapping from FQDN to selector
@@ -34,6 +114,18 @@ func QuerySelectors(ctx context.Context, selectors []string, domain string, time
 		fqdnToSelector[fqdn] = selector
 	}
 
+	selectorFor := func(fqdn string) string {
+		if selector, ok := fqdnToSelector[fqdn]; ok {
+			return selector
+		}
+		// Try to extract selector from FQDN format
+		parts := strings.Split(fqdn, "._domainkey.")
+		if len(parts) == 2 {
+			return parts[0]
+		}
+		return fqdn
+	}
+
 	// Process queries in a goroutine
 	go func() {
 		defer close(resultChan)
@@ -42,20 +134,21 @@ func QuerySelectors(ctx context.Context, selectors []string, domain string, time
 			return
 		}
 
+		nameServers, err := ParseNameServers(opts.NameServers)
+		if err != nil {
+			slog.Default().Error("invalid name servers", "error", err)
+			for _, fqdn := range fqdns {
+				resultChan <- &QueryResult{Selector: selectorFor(fqdn), FQDN: fqdn, Error: err, Status: StatusError}
+			}
+			return
+		}
+
 		// Create a context with timeout
-		queryCtx, cancel := context.WithTimeout(ctx, timeout)
+		queryCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
 		defer cancel()
 
-		// Create zdns resolver configuration with iterative mode
-		// This config is shared across all workers
-		config := zdns.NewResolverConfig()
-		// Use per-query timeout (zdns default is 15 seconds)
-		// The overall operation timeout is handled by queryCtx
-		config.Timeout = 15 * time.Second
-		config.IterativeTimeout = 8 * time.Second // Default iterative timeout
-		config.NetworkTimeout = 2 * time.Second   // Default network timeout
-		config.Retries = 3                        // Default retries
-		config.MaxDepth = 10                      // Default max depth
+		// This config, and so its cache, is shared across all workers
+		config := newResolverConfig(nameServers, opts.Cache)
 
 		// Create a channel to distribute FQDNs to workers
 		fqdnChan := make(chan string, len(fqdns))
@@ -64,8 +157,10 @@ func QuerySelectors(ctx context.Context, selectors []string, domain string, time
 		}
 		close(fqdnChan)
 
-		// Number of concurrent workers (similar to zdns default of 100 threads)
-		numWorkers := 100
+		numWorkers := opts.Workers
+		if numWorkers <= 0 {
+			numWorkers = DefaultWorkers
+		}
 		if len(fqdns) < numWorkers {
 			numWorkers = len(fqdns)
 		}
@@ -76,7 +171,7 @@ func QuerySelectors(ctx context.Context, selectors []string, domain string, time
 
 		// Start worker goroutines
 		for i := 0; i < numWorkers; i++ {
-			go func() {
+			go func(worker int) {
 				defer wg.Done()
 
 				// Each worker creates its own resolver (zdns resolvers are not thread-safe)
@@ -95,126 +190,35 @@ func QuerySelectors(ctx context.Context, selectors []string, domain string, time
 					select {
 					case <-queryCtx.Done():
 						// Context expired, send error for this FQDN and drain remaining
-						selector, ok := fqdnToSelector[fqdn]
-						if !ok {
-							parts := strings.Split(fqdn, "._domainkey.")
-							if len(parts) == 2 {
-								selector = parts[0]
-							} else {
-								selector = fqdn
-							}
-						}
 						resultChan <- &QueryResult{
-							Selector: selector,
+							Selector: selectorFor(fqdn),
 							FQDN:     fqdn,
 							Error:    fmt.Errorf("query timeout: %w", queryCtx.Err()),
 							Found:    false,
+							Status:   StatusTimeout,
 						}
 						// Drain remaining FQDNs from this worker's channel
 						for remainingFqdn := range fqdnChan {
-							remainingSelector, ok := fqdnToSelector[remainingFqdn]
-							if !ok {
-								parts := strings.Split(remainingFqdn, "._domainkey.")
-								if len(parts) == 2 {
-									remainingSelector = parts[0]
-								} else {
-									remainingSelector = remainingFqdn
-								}
-							}
 							resultChan <- &QueryResult{
-								Selector: remainingSelector,
+								Selector: selectorFor(remainingFqdn),
 								FQDN:     remainingFqdn,
 								Error:    fmt.Errorf("query timeout: %w", queryCtx.Err()),
 								Found:    false,
+								Status:   StatusTimeout,
 							}
 						}
 						return
 					default:
 					}
 
-					// Create DNS question for TXT record
-					question := &zdns.Question{
-						Name:  fqdn,
-						Type:  dns.TypeTXT,
-						Class: dns.ClassINET,
-					}
-
-					// Perform iterative lookup
-					result, trace, status, lookupErr := resolver.IterativeLookup(queryCtx, question)
-
-					// Map FQDN back to selector
-					selector, ok := fqdnToSelector[fqdn]
-					if !ok {
-						// Try to extract selector from FQDN format
-						parts := strings.Split(fqdn, "._domainkey.")
-						if len(parts) == 2 {
-							selector = parts[0]
-						} else {
-							selector = fqdn
-						}
-					}
-
-					queryResult := &QueryResult{
-						Selector: selector,
-						FQDN:     fqdn,
+					// Spread workers across the configured resolvers
+					// ExternalLookup modifies the name server, so each worker uses a copy
+					var nameServer *zdns.NameServer
+					if len(nameServers) > 0 {
+						ns := nameServers[worker%len(nameServers)]
+						nameServer = &ns
 					}
-
-					// Handle lookup errors
-					if lookupErr != nil {
-						queryResult.Error = fmt.Errorf("dns lookup error: %w", lookupErr)
-						queryResult.Found = false
-						resultChan <- queryResult
-						continue
-					}
-
-					// Handle status codes
-					if status != zdns.StatusNoError {
-						// Map status to error message
-						switch status {
-						case zdns.StatusNXDomain:
-							queryResult.Error = fmt.Errorf("domain not found: %s", status)
-						case zdns.StatusTimeout, zdns.StatusIterTimeout:
-							queryResult.Error = fmt.Errorf("query timeout: %s", status)
-						case zdns.StatusServFail:
-							queryResult.Error = fmt.Errorf("server failure: %s", status)
-						case zdns.StatusRefused:
-							queryResult.Error = fmt.Errorf("query refused: %s", status)
-						default:
-							queryResult.Error = fmt.Errorf("dns error: %s", status)
-						}
-						queryResult.Found = false
-						resultChan <- queryResult
-						continue
-					}
-
-					// Extract TXT records from result
-					if result != nil {
-						var txtRecords []string
-						for _, answer := range result.Answers {
-							if ans, ok := answer.(zdns.Answer); ok {
-								// Check if it's a TXT record
-								if ans.Type == "TXT" || ans.RrType == dns.TypeTXT {
-									if ans.Answer != "" {
-										// Clean up the answer - remove quotes and handle multi-string TXT records
-										answerText := strings.Trim(ans.Answer, "\"")
-										txtRecords = append(txtRecords, answerText)
-									}
-								}
-							}
-						}
-
-						if len(txtRecords) > 0 {
-							queryResult.TXT = txtRecords
-							queryResult.Found = true
-						} else {
-							queryResult.Found = false
-						}
-					} else {
-						queryResult.Found = false
-					}
-
-					// Suppress unused variable warning for trace
-					_ = trace
+					queryResult := lookupTXT(queryCtx, resolver, nameServer, selectorFor(fqdn), fqdn)
 
 					// Send result immediately (no need to collect all results first)
 					logger := slog.Default()
@@ -227,7 +231,7 @@ func QuerySelectors(ctx context.Context, selectors []string, domain string, time
 					}
 					resultChan <- queryResult
 				}
-			}()
+			}(i)
 		}
 
 		// Wait for all workers to complete
@@ -236,3 +240,97 @@ func QuerySelectors(ctx context.Context, selectors []string, domain string, time
 
 	return resultChan
 }
+
+// lookupTXT queries the TXT record of fqdn, iteratively from the root when
+// nameServer is nil or through the given recursive resolver otherwise
+func lookupTXT(ctx context.Context, resolver *zdns.Resolver, nameServer *zdns.NameServer, selector, fqdn string) *QueryResult {
+	// Create DNS question for TXT record
+	question := &zdns.Question{
+		Name:  fqdn,
+		Type:  dns.TypeTXT,
+		Class: dns.ClassINET,
+	}
+
+	var result *zdns.SingleQueryResult
+	var status zdns.Status
+	var lookupErr error
+	if nameServer != nil {
+		result, _, status, lookupErr = resolver.ExternalLookup(ctx, question, nameServer)
+	} else {
+		// Perform iterative lookup
+		result, _, status, lookupErr = resolver.IterativeLookup(ctx, question)
+	}
+
+	queryResult := newQueryResult(selector, fqdn, result, status, lookupErr)
+	if lookupErr != nil && ctx.Err() != nil {
+		queryResult.Status = StatusTimeout
+	}
+	return queryResult
+}
+
+// newQueryResult converts a zdns lookup outcome into a QueryResult
+func newQueryResult(selector, fqdn string, result *zdns.SingleQueryResult, status zdns.Status, lookupErr error) *QueryResult {
+	queryResult := &QueryResult{
+		Selector: selector,
+		FQDN:     fqdn,
+	}
+
+	// Handle lookup errors. zdns retries NXDOMAIN and other failures, then
+	// reports "out of retries" along with the final status, which is the
+	// more precise outcome.
+	if lookupErr != nil && (status == zdns.StatusNoError || status == zdns.StatusError || status == "") {
+		queryResult.Error = fmt.Errorf("dns lookup error: %w", lookupErr)
+		queryResult.Status = StatusError
+		return queryResult
+	}
+
+	// Handle status codes
+	if status != zdns.StatusNoError {
+		// Map status to error message
+		switch status {
+		case zdns.StatusNXDomain:
+			queryResult.Error = fmt.Errorf("domain not found: %s", status)
+			queryResult.Status = StatusNXDomain
+		case zdns.StatusTimeout, zdns.StatusIterTimeout:
+			queryResult.Error = fmt.Errorf("query timeout: %s", status)
+			queryResult.Status = StatusTimeout
+		case zdns.StatusServFail:
+			queryResult.Error = fmt.Errorf("server failure: %s", status)
+			queryResult.Status = StatusServFail
+		case zdns.StatusRefused:
+			queryResult.Error = fmt.Errorf("query refused: %s", status)
+			queryResult.Status = StatusRefused
+		default:
+			queryResult.Error = fmt.Errorf("dns error: %s", status)
+			queryResult.Status = StatusError
+		}
+		return queryResult
+	}
+
+	queryResult.Status = StatusNoError
+	queryResult.TXT = extractTXT(result)
+	queryResult.Found = len(queryResult.TXT) > 0
+	return queryResult
+}
+
+// extractTXT returns the TXT answers of a lookup result
+func extractTXT(result *zdns.SingleQueryResult) []string {
+	if result == nil {
+		return nil
+	}
+
+	var txtRecords []string
+	for _, answer := range result.Answers {
+		if ans, ok := answer.(zdns.Answer); ok {
+			// Check if it's a TXT record
+			if ans.Type == "TXT" || ans.RrType == dns.TypeTXT {
+				if ans.Answer != "" {
+					// Clean up the answer - remove quotes and handle multi-string TXT records
+					answerText := strings.Trim(ans.Answer, "\"")
+					txtRecords = append(txtRecords, answerText)
+				}
+			}
+		}
+	}
+	return txtRecords
+}
@@ -0,0 +1,74 @@
+package dns
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// RetryOptions controls the follow-up passes for failed selectors
+type RetryOptions struct {
+	// Passes is the maximum number of retry passes
+	Passes int
+	// Backoff is the delay before the first pass, doubled for every later pass
+	Backoff time.Duration
+	// Workers is the concurrency of the first pass, halved for every later pass
+	Workers int
+	// Timeout bounds each pass
+	Timeout time.Duration
+	// NameServers optionally sends retries through different resolvers
+	NameServers []string
+	// Cache is the resolution cache shared with the first pass
+	Cache *Cache
+}
+
+// Retryable reports whether a result failed in a way another attempt may fix
+func Retryable(result *QueryResult) bool {
+	switch result.Status {
+	case StatusTimeout, StatusServFail, StatusError:
+		return true
+	default:
+		return false
+	}
+}
+
+// RetrySelectors queries failed selectors again in up to opts.Passes passes
+// with exponential backoff and decreasing concurrency. Every result is passed
+// to handle; the selectors that still failed after the last pass are returned.
+func RetrySelectors(ctx context.Context, selectors []string, domain string, opts RetryOptions, handle func(*QueryResult)) []string {
+	pending := selectors
+	backoff := opts.Backoff
+	workers := opts.Workers
+
+	for pass := 1; pass <= opts.Passes && len(pending) > 0; pass++ {
+		slog.Info("retrying failed selectors", "pass", pass, "count", len(pending), "backoff", backoff, "workers", workers)
+
+		select {
+		case <-ctx.Done():
+			return pending
+		case <-time.After(backoff):
+		}
+
+		var failed []string
+		resultChan := QuerySelectors(ctx, pending, domain, Options{
+			Timeout:     opts.Timeout,
+			Workers:     workers,
+			NameServers: opts.NameServers,
+			Cache:       opts.Cache,
+		})
+		for result := range resultChan {
+			if Retryable(result) {
+				failed = append(failed, result.Selector)
+			}
+			handle(result)
+		}
+
+		pending = failed
+		backoff *= 2
+		if workers > 1 {
+			workers /= 2
+		}
+	}
+
+	return pending
+}
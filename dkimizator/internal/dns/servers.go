@@ -0,0 +1,97 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/zmap/zdns/v2/src/zdns"
+)
+
+// Server is a name server answering queries directly
+type Server struct {
+	// Name is the host name of an authoritative server, or the address of a public resolver
+	Name    string
+	Address string
+	// Authoritative is true for the domain's own name servers
+	Authoritative bool
+}
+
+// LookupNameServers resolves the authoritative name servers of a domain and
+// their IPv4 addresses, one Server per address
+func LookupNameServers(ctx context.Context, domain string, opts Options) ([]Server, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	resolver, err := zdns.InitResolver(newResolverConfig(nil, opts.Cache))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize zdns resolver: %w", err)
+	}
+	defer resolver.Close()
+
+	result, _, status, err := resolver.DoNSLookup(queryCtx, domain, nil, true, true, false)
+	if err != nil {
+		return nil, fmt.Errorf("NS lookup failed: %w", err)
+	}
+	if status != zdns.StatusNoError {
+		return nil, fmt.Errorf("NS lookup failed: %s", status)
+	}
+
+	var servers []Server
+	for _, record := range result.Servers {
+		for _, address := range record.IPv4Addresses {
+			servers = append(servers, Server{
+				Name:          record.Name,
+				Address:       net.JoinHostPort(address, "53"),
+				Authoritative: true,
+			})
+		}
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no name server addresses found for %s", domain)
+	}
+	return servers, nil
+}
+
+// PublicServers converts resolver addresses ("ip" or "ip:port") into servers
+func PublicServers(addrs []string) ([]Server, error) {
+	nameServers, err := ParseNameServers(addrs)
+	if err != nil {
+		return nil, err
+	}
+
+	servers := make([]Server, 0, len(nameServers))
+	for i, ns := range nameServers {
+		servers = append(servers, Server{
+			Name:    addrs[i],
+			Address: net.JoinHostPort(ns.IP.String(), fmt.Sprint(ns.Port)),
+		})
+	}
+	return servers, nil
+}
+
+// QueryServers sends the TXT query for selector to every server in turn and
+// returns one result per server, in the same order
+func QueryServers(ctx context.Context, selector, domain string, servers []Server, timeout time.Duration) ([]*QueryResult, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Never the shared cache, every server must actually be asked
+	resolver, err := zdns.InitResolver(newResolverConfig(nil, nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize zdns resolver: %w", err)
+	}
+	defer resolver.Close()
+
+	fqdn := fmt.Sprintf("%s._domainkey.%s", selector, domain)
+	results := make([]*QueryResult, 0, len(servers))
+	for _, server := range servers {
+		nameServers, err := ParseNameServers([]string{server.Address})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, lookupTXT(queryCtx, resolver, &nameServers[0], selector, fqdn))
+	}
+	return results, nil
+}
@@ -60,6 +60,18 @@ func TestGenerateSelectors(t *testing.T) {
 			domain:   "example.com",
 			expected: []string{"example"},
 		},
+		{
+			name:     "domain negative range",
+			rule:     "{D:-2--1}",
+			domain:   "mail.example.com",
+			expected: []string{"example.com"},
+		},
+		{
+			name:     "domain last part",
+			rule:     "{D:-1}",
+			domain:   "mail.example.com",
+			expected: []string{"com"},
+		},
 		{
 			name:   "complex pattern",
 			rule:   "mail{N:2005-2018}{O:-}{N:01-12}",
@@ -123,3 +135,60 @@ func TestGenerateSelectors(t *testing.T) {
 		})
 	}
 }
+
+func TestMutationRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		radius   int
+		expected []string
+	}{
+		{
+			name:     "key size and year month",
+			selector: "s2048-202103",
+			radius:   1,
+			expected: []string{"s{N:2047-2049}-202103", "s2048-{N:2020-2022}{N:01-12}"},
+		},
+		{
+			name:     "year",
+			selector: "mail2019",
+			radius:   2,
+			expected: []string{"mail{N:2017-2021}"},
+		},
+		{
+			name:     "zero padded counter",
+			selector: "k01",
+			radius:   2,
+			expected: []string{"k{N:00-03}"},
+		},
+		{
+			name:     "full date",
+			selector: "dk20200327",
+			radius:   1,
+			expected: []string{"dk{N:2019-2021}{N:01-12}27"},
+		},
+		{
+			name:     "no numbers",
+			selector: "google",
+			radius:   1,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := MutationRules(tt.selector, tt.radius)
+			if len(rules) != len(tt.expected) {
+				t.Fatalf("MutationRules() = %v, want %v", rules, tt.expected)
+			}
+			for i := range rules {
+				if rules[i] != tt.expected[i] {
+					t.Errorf("MutationRules()[%d] = %v, want %v", i, rules[i], tt.expected[i])
+				}
+				if err := GenerateSelectors(rules[i], "example.com", func(string) {}); err != nil {
+					t.Errorf("GenerateSelectors(%q) error = %v", rules[i], err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// digitsRegex matches the numeric components of a selector
+var digitsRegex = regexp.MustCompile(`\d+`)
+
+// MutationRules infers the numeric and date components of a discovered
+// selector and returns rules that generate its neighbours, one rule per
+// component with the rest of the selector held fixed:
+//   - YYYYMMDD and YYYYMM - every month of the surrounding years, keeping the day
+//   - YYYY - the surrounding years
+//   - any other number - the numbers within radius, keeping zero padding
+//
+// The rules use the regular pattern syntax, so GenerateSelectors expands them.
+func MutationRules(selector string, radius int) []string {
+	if radius < 1 {
+		radius = 1
+	}
+
+	var rules []string
+	for _, loc := range digitsRegex.FindAllStringIndex(selector, -1) {
+		prefix, digits, suffix := selector[:loc[0]], selector[loc[0]:loc[1]], selector[loc[1]:]
+
+		for _, pattern := range numericMutations(digits, radius) {
+			rules = append(rules, prefix+pattern+suffix)
+		}
+	}
+	return rules
+}
+
+// numericMutations returns the patterns replacing one numeric component
+func numericMutations(digits string, radius int) []string {
+	// Too long to be a counter or a date, e.g. a timestamp or a hash fragment
+	if len(digits) > 8 {
+		return nil
+	}
+
+	switch len(digits) {
+	case 8:
+		if year, ok := parseYearMonth(digits[:6]); ok {
+			return []string{fmt.Sprintf("{N:%d-%d}{N:01-12}%s", year-radius, year+radius, digits[6:])}
+		}
+	case 6:
+		if year, ok := parseYearMonth(digits); ok {
+			return []string{fmt.Sprintf("{N:%d-%d}{N:01-12}", year-radius, year+radius)}
+		}
+	case 4:
+		if year, ok := parseYear(digits); ok {
+			return []string{fmt.Sprintf("{N:%d-%d}", year-radius, year+radius)}
+		}
+	}
+
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return nil
+	}
+	start := n - radius
+	if start < 0 {
+		start = 0
+	}
+	end := n + radius
+
+	// Zero padded numbers keep their width, the generator pads to the start bound
+	if len(digits) > 1 && digits[0] == '0' {
+		return []string{fmt.Sprintf("{N:%0*d-%0*d}", len(digits), start, len(digits), end)}
+	}
+	return []string{fmt.Sprintf("{N:%d-%d}", start, end)}
+}
+
+// parseYear accepts years that plausibly appear in selectors
+func parseYear(digits string) (int, bool) {
+	year, err := strconv.Atoi(digits)
+	if err != nil || year < 1990 || year > 2099 {
+		return 0, false
+	}
+	return year, true
+}
+
+// parseYearMonth accepts YYYYMM with a plausible year and a valid month
+func parseYearMonth(digits string) (int, bool) {
+	year, ok := parseYear(digits[:4])
+	if !ok {
+		return 0, false
+	}
+	month, err := strconv.Atoi(digits[4:])
+	if err != nil || month < 1 || month > 12 {
+		return 0, false
+	}
+	return year, true
+}
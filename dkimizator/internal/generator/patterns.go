@@ -3,6 +3,7 @@ package generator
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -139,12 +140,71 @@ func parseNumericArgs(args string) []string {
 	return []string{parts[0], parts[1]}
 }
 
+// domainArgsRegex matches one index or a range of indices, either of which may be negative
+var domainArgsRegex = regexp.MustCompile(`^(-?\d+)(?:-(-?\d+))?$`)
+
 func parseDomainArgs(args string) []string {
 	if args == "" {
 		return []string{}
 	}
-	parts := strings.Split(args, "-")
-	return parts
+	m := domainArgsRegex.FindStringSubmatch(args)
+	if m == nil {
+		// Keep the raw value so validation can report it
+		return []string{args}
+	}
+	if m[2] == "" {
+		return []string{m[1]}
+	}
+	return []string{m[1], m[2]}
+}
+
+// Validate checks a parsed pattern for arguments the generator would silently
+// ignore and returns a descriptive error for the first problem found
+func (p Pattern) Validate() error {
+	switch p.Type {
+	case PatternNumeric:
+		if len(p.Args) != 2 {
+			return fmt.Errorf("%s: numeric range must be {N:start-end}", p.Original)
+		}
+		start, err1 := strconv.Atoi(p.Args[0])
+		end, err2 := strconv.Atoi(p.Args[1])
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("%s: numeric range bounds must be integers", p.Original)
+		}
+		if start > end {
+			return fmt.Errorf("%s: reversed numeric range (%d > %d)", p.Original, start, end)
+		}
+	case PatternDomain:
+		for _, arg := range p.Args {
+			idx, err := strconv.Atoi(arg)
+			if err != nil {
+				return fmt.Errorf("%s: domain index %q is not an integer", p.Original, arg)
+			}
+			if idx == 0 {
+				return fmt.Errorf("%s: domain indices are 1-based, 0 is not valid", p.Original)
+			}
+		}
+		if len(p.Args) == 2 {
+			start, _ := strconv.Atoi(p.Args[0])
+			end, _ := strconv.Atoi(p.Args[1])
+			if (start < 0) == (end < 0) && start > end {
+				return fmt.Errorf("%s: reversed domain range (%d > %d)", p.Original, start, end)
+			}
+		}
+	case PatternList:
+		if len(p.Args) == 0 {
+			return fmt.Errorf("%s: empty list", p.Original)
+		}
+	case PatternOptional:
+		if len(p.Args) != 1 || p.Args[0] == "" {
+			return fmt.Errorf("%s: empty optional string", p.Original)
+		}
+	case PatternString:
+		if strings.ContainsAny(p.Raw, "{}") {
+			return fmt.Errorf("%s: unbalanced or malformed pattern braces", p.Original)
+		}
+	}
+	return nil
 }
 
 func parseListArgs(args string) []string {
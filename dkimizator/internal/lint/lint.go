@@ -0,0 +1,162 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/ducksify/panop-tools/dkimizator/internal/generator"
+	"github.com/ducksify/panop-tools/dkimizator/internal/rules"
+)
+
+// Issue represents a problem found in a rules file
+type Issue struct {
+	Line    int
+	Rule    string
+	Message string
+}
+
+// selectorRegex matches the characters allowed in a DNS label sequence
+var selectorRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// coverageDomain is used to expand rules for the coverage check. Its labels
+// cannot appear in literal rule text, so a {D} selector is only covered by
+// another {D} selector that is the same for every domain.
+const coverageDomain = "@1.@2.@3.@4.@5"
+
+// Lint checks rules for bad syntax, empty expansions, reversed ranges,
+// duplicates and rules whose output is fully covered by other rules.
+// Domain patterns are expanded against the given sample domain.
+func Lint(lines []rules.Line, domain string) []Issue {
+	var issues []Issue
+
+	firstSeen := make(map[string]int)
+	expansions := make([][]string, len(lines))
+
+	for i, line := range lines {
+		// Duplicate rules
+		if first, ok := firstSeen[line.Rule]; ok {
+			issues = append(issues, Issue{
+				Line:    line.Number,
+				Rule:    line.Rule,
+				Message: fmt.Sprintf("duplicate of rule on line %d", first),
+			})
+			continue
+		}
+		firstSeen[line.Rule] = line.Number
+
+		// Syntax and argument errors
+		patterns, err := generator.ParsePattern(line.Rule)
+		if err != nil {
+			issues = append(issues, Issue{Line: line.Number, Rule: line.Rule, Message: err.Error()})
+			continue
+		}
+		invalid := false
+		for _, pattern := range patterns {
+			if err := pattern.Validate(); err != nil {
+				issues = append(issues, Issue{Line: line.Number, Rule: line.Rule, Message: err.Error()})
+				invalid = true
+			}
+		}
+		if invalid {
+			continue
+		}
+
+		// Expansion checks
+		selectors := make(map[string]bool)
+		badSelector := ""
+		generator.GenerateSelectors(line.Rule, domain, func(selector string) {
+			if selector == "" {
+				return
+			}
+			if badSelector == "" && !selectorRegex.MatchString(selector) {
+				badSelector = selector
+			}
+			selectors[selector] = true
+		})
+
+		if len(selectors) == 0 {
+			issues = append(issues, Issue{Line: line.Number, Rule: line.Rule, Message: "rule expands to no selectors"})
+			continue
+		}
+		if badSelector != "" {
+			issues = append(issues, Issue{
+				Line:    line.Number,
+				Rule:    line.Rule,
+				Message: fmt.Sprintf("rule generates invalid selector %q", badSelector),
+			})
+		}
+
+		var expansion []string
+		generator.GenerateSelectors(line.Rule, coverageDomain, func(selector string) {
+			if selector != "" {
+				expansion = append(expansion, selector)
+			}
+		})
+		expansions[i] = uniqueStrings(expansion)
+	}
+
+	issues = append(issues, coveredRules(lines, expansions)...)
+
+	sort.SliceStable(issues, func(a, b int) bool {
+		return issues[a].Line < issues[b].Line
+	})
+
+	return issues
+}
+
+// coveredRules reports rules whose selectors are all generated by other rules.
+// Rules are checked from last to first and a reported rule stops counting as
+// coverage, so of two equivalent rules only the later one is reported.
+func coveredRules(lines []rules.Line, expansions [][]string) []Issue {
+	var issues []Issue
+
+	counts := make(map[string]int)
+	for _, expansion := range expansions {
+		for _, selector := range expansion {
+			counts[selector]++
+		}
+	}
+
+	for i := len(lines) - 1; i >= 0; i-- {
+		expansion := expansions[i]
+		if len(expansion) == 0 {
+			continue
+		}
+
+		covered := true
+		for _, selector := range expansion {
+			if counts[selector] < 2 {
+				covered = false
+				break
+			}
+		}
+		if !covered {
+			continue
+		}
+
+		for _, selector := range expansion {
+			counts[selector]--
+		}
+		issues = append(issues, Issue{
+			Line:    lines[i].Number,
+			Rule:    lines[i].Rule,
+			Message: "all selectors are already generated by other rules",
+		})
+	}
+
+	return issues
+}
+
+// uniqueStrings removes duplicates from a slice, keeping the first occurrence
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := values[:0]
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			out = append(out, value)
+		}
+	}
+	return out
+}
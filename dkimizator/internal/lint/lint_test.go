@@ -0,0 +1,71 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ducksify/panop-tools/dkimizator/internal/rules"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []string
+		expected map[int]string // line -> message substring
+	}{
+		{
+			name:     "clean rules",
+			rules:    []string{"default", "s{N:1-5}", "{D:1}{O:-}{N:2020-2026}", "{D:-2--1}"},
+			expected: map[int]string{},
+		},
+		{
+			name:  "malformed arguments",
+			rules: []string{"a{N:1}", "b{N:a-b}", "c{N:5-1}", "d{X:1}", "e{L:}", "f{N:1-3", "g{D:-1-x}", "h{D:-1--2}"},
+			expected: map[int]string{
+				1: "numeric range must be",
+				2: "must be integers",
+				3: "reversed numeric range",
+				4: "unknown pattern type",
+				5: "empty list",
+				6: "malformed pattern braces",
+				7: "is not an integer",
+				8: "reversed domain range",
+			},
+		},
+		{
+			name:  "duplicates and coverage",
+			rules: []string{"k{N:1-3}", "mail", "k{L:1,2}", "mail", "x{L:a,b}", "x{L:b,a}"},
+			expected: map[int]string{
+				3: "already generated by other rules",
+				4: "duplicate of rule on line 2",
+				6: "already generated by other rules",
+			},
+		},
+		{
+			name:     "domain selectors are not covered by literals",
+			rules:    []string{"{D:1}", "mail", "example"},
+			expected: map[int]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := make([]rules.Line, len(tt.rules))
+			for i, rule := range tt.rules {
+				lines[i] = rules.Line{Number: i + 1, Rule: rule}
+			}
+
+			issues := Lint(lines, "mail.example.com")
+
+			if len(issues) != len(tt.expected) {
+				t.Fatalf("Lint() count mismatch: got %d, want %d: %+v", len(issues), len(tt.expected), issues)
+			}
+			for _, issue := range issues {
+				want, ok := tt.expected[issue.Line]
+				if !ok || !strings.Contains(issue.Message, want) {
+					t.Errorf("Lint() unexpected issue on line %d: %q, want %q", issue.Line, issue.Message, want)
+				}
+			}
+		})
+	}
+}
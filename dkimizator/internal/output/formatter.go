@@ -4,27 +4,49 @@ import (
 	"encoding/json"
 	"io"
 
+	"github.com/ducksify/panop-tools/dkimizator/internal/consistency"
 	"github.com/ducksify/panop-tools/dkimizator/internal/crypto"
 )
 
+// How a selector was discovered, reported in Result.DiscoveredBy
+const (
+	DiscoveredByRules    = "rules"
+	DiscoveredByMutation = "mutation"
+	DiscoveredByZoneFile = "zone_file"
+)
+
 // Result represents a single DKIM result for JSON output
 type Result struct {
-	FQDN        string   `json:"fqdn"`
-	TXT         []string `json:"txt"`
-	Selector    string   `json:"selector"`
-	Domain      string   `json:"domain"`
-	Fingerprint string   `json:"fingerprint"`
-	Size        int      `json:"size"`
-	Modulus     string   `json:"modulus"`
-	Exponent    string   `json:"exponent"`
-	Mode        string   `json:"mode"`
-	X509Key     string   `json:"x509_key"`
+	FQDN         string   `json:"fqdn"`
+	TXT          []string `json:"txt"`
+	Selector     string   `json:"selector"`
+	Domain       string   `json:"domain"`
+	Fingerprint  string   `json:"fingerprint"`
+	Size         int      `json:"size"`
+	Modulus      string   `json:"modulus"`
+	Exponent     string   `json:"exponent"`
+	Mode         string   `json:"mode"`
+	X509Key      string   `json:"x509_key"`
+	DiscoveredBy string   `json:"discovered_by,omitempty"`
+	Rules        []string `json:"rules,omitempty"`
+}
+
+// RuleCost records how many selectors a rule generated and how many of them were found
+type RuleCost struct {
+	Rule      string `json:"rule"`
+	Selectors int    `json:"selectors"`
+	Hits      int    `json:"hits"`
 }
 
 // Output represents the complete JSON output structure
 type Output struct {
-	Count   int      `json:"count"`
-	Results []Result `json:"results"`
+	Domain          string                      `json:"domain,omitempty"`
+	Count           int                         `json:"count"`
+	Results         []Result                    `json:"results"`
+	NoDKIMNamespace bool                        `json:"no_dkim_namespace,omitempty"`
+	Summary         *Summary                    `json:"summary,omitempty"`
+	Rules           []RuleCost                  `json:"rules,omitempty"`
+	Consistency     []consistency.SelectorCheck `json:"consistency,omitempty"`
 }
 
 // Formatter handles output formatting
@@ -32,6 +54,12 @@ type Formatter struct {
 	writer  io.Writer
 	quiet   bool
 	results []Result
+	summary *Summary
+	rules   []RuleCost
+	checks  []consistency.SelectorCheck
+	domain  string
+	// noNamespace is set when _domainkey does not exist (RFC 8020)
+	noNamespace bool
 }
 
 // NewFormatter creates a new output formatter
@@ -44,27 +72,75 @@ func NewFormatter(writer io.Writer, quiet bool) *Formatter {
 }
 
 // AddResult adds a result to the collection
-func (f *Formatter) AddResult(fqdn string, txt []string, keyInfo *crypto.KeyInfo, domain, selector, mode string, x509Key string) {
+func (f *Formatter) AddResult(fqdn string, txt []string, keyInfo *crypto.KeyInfo, domain, selector, mode string, x509Key string, discoveredBy string, rules []string) {
 	result := Result{
-		FQDN:        fqdn,
-		TXT:         txt,
-		Selector:    selector,
-		Domain:      domain,
-		Fingerprint: keyInfo.Fingerprint,
-		Size:        keyInfo.Size,
-		Modulus:     keyInfo.Modulus.String(),
-		Exponent:    keyInfo.Exponent.String(),
-		Mode:        mode,
-		X509Key:     x509Key,
+		FQDN:         fqdn,
+		TXT:          txt,
+		Selector:     selector,
+		Domain:       domain,
+		Fingerprint:  keyInfo.Fingerprint,
+		Size:         keyInfo.Size,
+		Modulus:      keyInfo.Modulus.String(),
+		Exponent:     keyInfo.Exponent.String(),
+		Mode:         mode,
+		X509Key:      x509Key,
+		DiscoveredBy: discoveredBy,
+		Rules:        rules,
 	}
 	f.results = append(f.results, result)
 }
 
+// SetSummary attaches scan completeness metrics to the output
+func (f *Formatter) SetSummary(summary *Summary) {
+	f.summary = summary
+}
+
+// SetRuleCosts attaches per-rule query costs to the output. Hits are counted
+// from the collected results when the output is written.
+func (f *Formatter) SetRuleCosts(costs []RuleCost) {
+	f.rules = costs
+}
+
+// SetConsistency attaches the multi-resolver consistency checks to the output
+func (f *Formatter) SetConsistency(checks []consistency.SelectorCheck) {
+	f.checks = checks
+}
+
+// SetDomain names the scanned domain, telling apart the outputs of a batch run
+func (f *Formatter) SetDomain(domain string) {
+	f.domain = domain
+}
+
+// SetNoDKIMNamespace marks the domain as having no _domainkey node at all
+func (f *Formatter) SetNoDKIMNamespace() {
+	f.noNamespace = true
+}
+
 // OutputJSON outputs all collected results as JSON
 func (f *Formatter) OutputJSON() error {
+	if len(f.rules) > 0 {
+		index := make(map[string]int, len(f.rules))
+		for i := range f.rules {
+			index[f.rules[i].Rule] = i
+			f.rules[i].Hits = 0
+		}
+		for _, result := range f.results {
+			for _, rule := range result.Rules {
+				if i, ok := index[rule]; ok {
+					f.rules[i].Hits++
+				}
+			}
+		}
+	}
+
 	output := Output{
-		Count:   len(f.results),
-		Results: f.results,
+		Domain:          f.domain,
+		Count:           len(f.results),
+		Results:         f.results,
+		NoDKIMNamespace: f.noNamespace,
+		Summary:         f.summary,
+		Rules:           f.rules,
+		Consistency:     f.checks,
 	}
 	return json.NewEncoder(f.writer).Encode(output)
 }
@@ -0,0 +1,57 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Progress writes a single, periodically refreshed status line
+type Progress struct {
+	writer   io.Writer
+	total    int
+	start    time.Time
+	last     time.Time
+	interval time.Duration
+}
+
+// NewProgress creates a progress reporter for total queries
+func NewProgress(writer io.Writer, total int) *Progress {
+	return &Progress{
+		writer:   writer,
+		total:    total,
+		start:    time.Now(),
+		interval: 200 * time.Millisecond,
+	}
+}
+
+// Update redraws the status line, at most once per refresh interval
+func (p *Progress) Update(done, found, failed int) {
+	now := time.Now()
+	if done < p.total && now.Sub(p.last) < p.interval {
+		return
+	}
+	p.last = now
+
+	percent := 100.0
+	if p.total > 0 {
+		percent = float64(done) * 100 / float64(p.total)
+	}
+	fmt.Fprintf(p.writer, "\r\033[K%d/%d queries (%.1f%%), %d found, %d failed, %s elapsed",
+		done, p.total, percent, found, failed, now.Sub(p.start).Round(time.Second))
+}
+
+// Done ends the status line
+func (p *Progress) Done() {
+	fmt.Fprint(p.writer, "\r\033[K")
+}
+
+// IsTerminal reports whether the file is attached to a terminal
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
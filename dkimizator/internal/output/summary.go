@@ -0,0 +1,54 @@
+package output
+
+import (
+	"time"
+
+	"github.com/ducksify/panop-tools/dkimizator/internal/dns"
+)
+
+// Summary describes how complete a scan was
+type Summary struct {
+	Selectors    int      `json:"selectors"`
+	Queries      int      `json:"queries"`
+	NoError      int      `json:"noerror"`
+	NXDomain     int      `json:"nxdomain"`
+	ServFail     int      `json:"servfail"`
+	Refused      int      `json:"refused"`
+	Timeout      int      `json:"timeout"`
+	Errors       int      `json:"errors"`
+	Retried      int      `json:"retried"`              // queried again in retry passes
+	Unresolved   []string `json:"unresolved,omitempty"` // still failing at the end, mutations included
+	DurationMS   int64    `json:"duration_ms"`
+	Completeness float64  `json:"completeness"`
+	Complete     bool     `json:"complete"`
+}
+
+// Record counts the final status of a single selector
+func (s *Summary) Record(status string) {
+	s.Selectors++
+	switch status {
+	case dns.StatusNoError:
+		s.NoError++
+	case dns.StatusNXDomain:
+		s.NXDomain++
+	case dns.StatusServFail:
+		s.ServFail++
+	case dns.StatusRefused:
+		s.Refused++
+	case dns.StatusTimeout:
+		s.Timeout++
+	default:
+		s.Errors++
+	}
+}
+
+// Finish sets the duration and marks the scan complete when the share of
+// conclusive answers (NOERROR or NXDOMAIN) reaches minCompleteness
+func (s *Summary) Finish(duration time.Duration, minCompleteness float64) {
+	s.DurationMS = duration.Milliseconds()
+	s.Completeness = 1
+	if s.Selectors > 0 {
+		s.Completeness = float64(s.NoError+s.NXDomain) / float64(s.Selectors)
+	}
+	s.Complete = s.Completeness >= minCompleteness
+}
@@ -13,6 +13,12 @@ import (
 // Loader handles loading rules from local files or URLs
 type Loader struct{}
 
+// Line is a rule together with its 1-based line number in the source
+type Line struct {
+	Number int
+	Rule   string
+}
+
 // NewLoader creates a new rules loader
 func NewLoader() *Loader {
 	return &Loader{}
@@ -20,6 +26,20 @@ func NewLoader() *Loader {
 
 // LoadRules loads rules from a file path or URL
 func (l *Loader) LoadRules(source string) ([]string, error) {
+	lines, err := l.LoadRuleLines(source)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]string, 0, len(lines))
+	for _, line := range lines {
+		rules = append(rules, line.Rule)
+	}
+	return rules, nil
+}
+
+// LoadRuleLines loads rules from a file path or URL, keeping their line numbers
+func (l *Loader) LoadRuleLines(source string) ([]Line, error) {
 	var reader io.Reader
 
 	// Check if it's a URL
@@ -49,11 +69,13 @@ func (l *Loader) LoadRules(source string) ([]string, error) {
 }
 
 // parseRules parses rules from a reader, handling comments, blank lines, and EoF marker
-func (l *Loader) parseRules(reader io.Reader) ([]string, error) {
-	var rules []string
+func (l *Loader) parseRules(reader io.Reader) ([]Line, error) {
+	var rules []Line
 	scanner := bufio.NewScanner(reader)
+	number := 0
 
 	for scanner.Scan() {
+		number++
 		line := scanner.Text()
 		// Strip spaces
 		line = strings.ReplaceAll(line, " ", "")
@@ -74,7 +96,7 @@ func (l *Loader) parseRules(reader io.Reader) ([]string, error) {
 			break
 		}
 
-		rules = append(rules, line)
+		rules = append(rules, Line{Number: number, Rule: line})
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -0,0 +1,123 @@
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ducksify/panop-tools/dkimizator/internal/output"
+)
+
+// RuleStats aggregates the effectiveness of a rule over many scans
+type RuleStats struct {
+	Rule string `json:"rule"`
+	// Scans is the number of scans that ran the rule
+	Scans int `json:"scans"`
+	// ScansWithHits is the number of scans in which the rule found a selector
+	ScansWithHits int `json:"scans_with_hits"`
+	// Queries is the total number of selectors the rule generated
+	Queries int `json:"queries"`
+	// Hits is the total number of selectors found through the rule
+	Hits int `json:"hits"`
+	// HitRate is ScansWithHits / Scans
+	HitRate float64 `json:"hit_rate"`
+	// HitsPerQuery is Hits / Queries, the rule's yield per DNS query
+	HitsPerQuery float64 `json:"hits_per_query"`
+}
+
+// Report is the output of the stats mode
+type Report struct {
+	Scans int         `json:"scans"`
+	Rules []RuleStats `json:"rules"`
+	// NeverHit lists rules that did not find a selector in any scan
+	NeverHit []string `json:"never_hit"`
+}
+
+// Collector aggregates scan outputs
+type Collector struct {
+	scans int
+	rules map[string]*RuleStats
+	order []string
+}
+
+// NewCollector creates a new stats collector
+func NewCollector() *Collector {
+	return &Collector{
+		rules: make(map[string]*RuleStats),
+	}
+}
+
+// Add aggregates one scan output. Scans skipped for lack of a _domainkey
+// node ran no rule and are ignored.
+func (c *Collector) Add(scan *output.Output) {
+	if scan.NoDKIMNamespace {
+		return
+	}
+	c.scans++
+	for _, cost := range scan.Rules {
+		stats, ok := c.rules[cost.Rule]
+		if !ok {
+			stats = &RuleStats{Rule: cost.Rule}
+			c.rules[cost.Rule] = stats
+			c.order = append(c.order, cost.Rule)
+		}
+		stats.Scans++
+		stats.Queries += cost.Selectors
+		stats.Hits += cost.Hits
+		if cost.Hits > 0 {
+			stats.ScansWithHits++
+		}
+	}
+}
+
+// AddReader aggregates every scan output in a stream of JSON documents
+func (c *Collector) AddReader(reader io.Reader) error {
+	decoder := json.NewDecoder(reader)
+	for {
+		var scan output.Output
+		err := decoder.Decode(&scan)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode scan output: %w", err)
+		}
+		c.Add(&scan)
+	}
+}
+
+// Report returns the aggregated statistics, most productive rules first:
+// by hits per query, then by hit rate, then by the original rule order
+func (c *Collector) Report() *Report {
+	report := &Report{
+		Scans:    c.scans,
+		Rules:    make([]RuleStats, 0, len(c.order)),
+		NeverHit: make([]string, 0),
+	}
+
+	for _, rule := range c.order {
+		stats := *c.rules[rule]
+		if stats.Scans > 0 {
+			stats.HitRate = float64(stats.ScansWithHits) / float64(stats.Scans)
+		}
+		if stats.Queries > 0 {
+			stats.HitsPerQuery = float64(stats.Hits) / float64(stats.Queries)
+		}
+		if stats.Hits == 0 {
+			report.NeverHit = append(report.NeverHit, rule)
+		}
+		report.Rules = append(report.Rules, stats)
+	}
+
+	sort.SliceStable(report.Rules, func(i, j int) bool {
+		a, b := report.Rules[i], report.Rules[j]
+		if a.HitsPerQuery != b.HitsPerQuery {
+			return a.HitsPerQuery > b.HitsPerQuery
+		}
+		return a.HitRate > b.HitRate
+	})
+
+	return report
+}
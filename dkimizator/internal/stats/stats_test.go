@@ -0,0 +1,40 @@
+package stats
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCollector(t *testing.T) {
+	scans := strings.Join([]string{
+		`{"domain":"a.com","rules":[{"rule":"default","selectors":1,"hits":1},{"rule":"s{N:1-4}","selectors":4,"hits":0}]}`,
+		`{"domain":"b.com","rules":[{"rule":"default","selectors":1,"hits":0},{"rule":"s{N:1-4}","selectors":4,"hits":2}]}`,
+		// Skipped by the RFC 8020 pre-flight, no rule ran
+		`{"domain":"c.com","no_dkim_namespace":true,"summary":{"queries":1},"rules":[{"rule":"default","selectors":1,"hits":0},{"rule":"never","selectors":3,"hits":0}]}`,
+	}, "\n")
+
+	collector := NewCollector()
+	if err := collector.AddReader(strings.NewReader(scans)); err != nil {
+		t.Fatalf("AddReader() error: %v", err)
+	}
+	report := collector.Report()
+
+	if report.Scans != 2 {
+		t.Errorf("Scans = %d, want 2", report.Scans)
+	}
+	expected := []RuleStats{
+		{Rule: "default", Scans: 2, ScansWithHits: 1, Queries: 2, Hits: 1, HitRate: 0.5, HitsPerQuery: 0.5},
+		{Rule: "s{N:1-4}", Scans: 2, ScansWithHits: 1, Queries: 8, Hits: 2, HitRate: 0.5, HitsPerQuery: 0.25},
+	}
+	if len(report.Rules) != len(expected) {
+		t.Fatalf("Rules = %+v, want %+v", report.Rules, expected)
+	}
+	for i, want := range expected {
+		if report.Rules[i] != want {
+			t.Errorf("Rules[%d] = %+v, want %+v", i, report.Rules[i], want)
+		}
+	}
+	if len(report.NeverHit) != 0 {
+		t.Errorf("NeverHit = %v, want none", report.NeverHit)
+	}
+}
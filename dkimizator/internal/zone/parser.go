@@ -0,0 +1,88 @@
+package zone
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/zmap/dns"
+)
+
+// Record represents a DKIM TXT record found in a zone file
+type Record struct {
+	Selector string
+	Domain   string
+	FQDN     string
+	TXT      []string
+}
+
+// ParseFile parses a BIND-format zone file or saved AXFR dump from disk
+func ParseFile(path string, origin string) ([]*Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zone file: %w", err)
+	}
+	defer file.Close()
+
+	return Parse(file, origin, path)
+}
+
+// Parse reads zone data and returns every *._domainkey TXT record in it.
+// The origin is used for relative names until the data sets its own $ORIGIN.
+// AXFR dumps (e.g. "dig axfr" output) are accepted since their ";;" lines are
+// plain zone file comments.
+func Parse(reader io.Reader, origin string, file string) ([]*Record, error) {
+	parser := dns.NewZoneParser(reader, origin, file)
+
+	var records []*Record
+	seen := make(map[string]bool)
+
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		txt, isTXT := rr.(*dns.TXT)
+		if !isTXT {
+			continue
+		}
+
+		fqdn := strings.ToLower(strings.TrimSuffix(txt.Hdr.Name, "."))
+		selector, domain, ok := splitDomainKey(fqdn)
+		if !ok {
+			continue
+		}
+
+		// AXFR dumps repeat the SOA and may contain the same RR twice
+		key := fqdn + "\x00" + strings.Join(txt.Txt, "")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		records = append(records, &Record{
+			Selector: selector,
+			Domain:   domain,
+			FQDN:     fqdn,
+			TXT:      txt.Txt,
+		})
+	}
+
+	if err := parser.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse zone file: %w", err)
+	}
+
+	return records, nil
+}
+
+// splitDomainKey splits "<selector>._domainkey.<domain>" into its parts
+func splitDomainKey(fqdn string) (string, string, bool) {
+	idx := strings.Index(fqdn, "._domainkey.")
+	if idx <= 0 {
+		return "", "", false
+	}
+
+	domain := fqdn[idx+len("._domainkey."):]
+	if domain == "" {
+		return "", "", false
+	}
+
+	return fqdn[:idx], domain, true
+}
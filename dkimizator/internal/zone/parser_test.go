@@ -0,0 +1,77 @@
+package zone
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		origin   string
+		expected []Record
+	}{
+		{
+			name:   "bind zone file with relative names",
+			origin: "example.com",
+			data: `$TTL 3600
+@	IN SOA ns1 hostmaster 1 7200 3600 1209600 3600
+@	IN NS ns1
+www	IN A 192.0.2.1
+@	IN TXT "v=spf1 -all"
+google._domainkey	IN TXT "v=DKIM1; k=rsa; " "p=MIGf"
+s1._domainkey.mail	IN TXT "v=DKIM1; p=ABCD"
+`,
+			expected: []Record{
+				{Selector: "google", Domain: "example.com", FQDN: "google._domainkey.example.com", TXT: []string{"v=DKIM1; k=rsa; ", "p=MIGf"}},
+				{Selector: "s1", Domain: "mail.example.com", FQDN: "s1._domainkey.mail.example.com", TXT: []string{"v=DKIM1; p=ABCD"}},
+			},
+		},
+		{
+			name: "axfr dump with repeated records",
+			data: `; <<>> DiG 9.18 <<>> axfr example.org
+;; global options: +cmd
+example.org.		3600	IN	SOA	ns1.example.org. hostmaster.example.org. 1 7200 3600 1209600 3600
+Selector1._domainkey.example.org. 3600 IN TXT	"v=DKIM1; p=XYZ"
+selector1._domainkey.example.org. 3600 IN TXT	"v=DKIM1; p=XYZ"
+_domainkey.example.org.	3600	IN	TXT	"o=~"
+example.org.		3600	IN	SOA	ns1.example.org. hostmaster.example.org. 1 7200 3600 1209600 3600
+;; Query time: 12 msec
+`,
+			expected: []Record{
+				{Selector: "selector1", Domain: "example.org", FQDN: "selector1._domainkey.example.org", TXT: []string{"v=DKIM1; p=XYZ"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := Parse(strings.NewReader(tt.data), tt.origin, "test")
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			if len(records) != len(tt.expected) {
+				t.Fatalf("Parse() count mismatch: got %d, want %d", len(records), len(tt.expected))
+			}
+
+			for i, want := range tt.expected {
+				got := records[i]
+				if got.Selector != want.Selector || got.Domain != want.Domain || got.FQDN != want.FQDN {
+					t.Errorf("Parse() record[%d] = %+v, want %+v", i, *got, want)
+				}
+				if strings.Join(got.TXT, "|") != strings.Join(want.TXT, "|") {
+					t.Errorf("Parse() record[%d].TXT = %q, want %q", i, got.TXT, want.TXT)
+				}
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse(strings.NewReader("foo IN A not-an-address\n"), "example.com", "test")
+	if err == nil {
+		t.Error("Parse() expected error for malformed zone data")
+	}
+}
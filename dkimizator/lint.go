@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+
+	"github.com/ducksify/panop-tools/dkimizator/internal/lint"
+	"github.com/ducksify/panop-tools/dkimizator/internal/rules"
+)
+
+// runLint implements "dkimizator lint <rules>..." and returns the exit code:
+// 0 when all files are clean, 1 when problems were found, 2 on usage errors
+func runLint(args []string) int {
+	fs := pflag.NewFlagSet("lint", pflag.ContinueOnError)
+	domain := fs.String("domain", "mail.example.co.uk", "Sample domain used to check {D} expansions")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dkimizator lint [options] <rules>...\n\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	loader := rules.NewLoader()
+	exitCode := 0
+
+	for _, source := range fs.Args() {
+		lines, err := loader.LoadRuleLines(source)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", source, err)
+			exitCode = 1
+			continue
+		}
+
+		for _, issue := range lint.Lint(lines, *domain) {
+			fmt.Fprintf(os.Stdout, "%s:%d: %s (%s)\n", source, issue.Line, issue.Message, issue.Rule)
+			exitCode = 1
+		}
+	}
+
+	return exitCode
+}
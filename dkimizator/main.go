@@ -5,7 +5,6 @@ import (
 	"log/slog"
 	"os"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/spf13/pflag"
@@ -14,12 +13,22 @@ import (
 	"github.com/ducksify/panop-tools/dkimizator/internal/crypto"
 	"github.com/ducksify/panop-tools/dkimizator/internal/dkim"
 	"github.com/ducksify/panop-tools/dkimizator/internal/dns"
-	"github.com/ducksify/panop-tools/dkimizator/internal/generator"
 	"github.com/ducksify/panop-tools/dkimizator/internal/output"
 	"github.com/ducksify/panop-tools/dkimizator/internal/rules"
+	"github.com/ducksify/panop-tools/dkimizator/internal/zone"
 )
 
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "lint":
+			os.Exit(runLint(os.Args[2:]))
+		case "stats":
+			os.Exit(runStats(os.Args[2:]))
+		}
+	}
+
 	// Set up viper
 	viper.SetEnvPrefix("DKIMIZATOR")
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
@@ -29,6 +38,16 @@ func main() {
 	viper.SetDefault("log-level", "warn")
 	viper.SetDefault("timeout", 60*time.Second)
 	viper.SetDefault("quiet", false)
+	viper.SetDefault("min-completeness", 0.95)
+	viper.SetDefault("mutation-rounds", 2)
+	viper.SetDefault("mutation-radius", 2)
+	viper.SetDefault("mutation-max", 500)
+	viper.SetDefault("consistency", false)
+	viper.SetDefault("ignore-rfc8020", false)
+	viper.SetDefault("retry-passes", 1)
+	viper.SetDefault("retry-backoff", 2*time.Second)
+	viper.SetDefault("retry-workers", 20)
+	viper.SetDefault("cache-size", dns.DefaultCacheSize)
 
 	// Bind flags
 	pflag.String("domain", "", "Domain to scan for DKIM selectors (required)")
@@ -36,6 +55,20 @@ func main() {
 	pflag.Bool("quiet", false, "Quiet mode (minimal output)")
 	pflag.String("log-level", "info", "Log level (debug, info, warn, error)")
 	pflag.Duration("timeout", 60*time.Second, "DNS query timeout")
+	pflag.Float64("min-completeness", 0.95, "Minimum share of conclusive DNS answers for a scan to be marked complete")
+	pflag.Int("retry-passes", 1, "Number of retry passes for failed and timed-out selectors")
+	pflag.Duration("retry-backoff", 2*time.Second, "Delay before the first retry pass, doubled for each later pass")
+	pflag.Int("retry-workers", 20, "Concurrency of the first retry pass, halved for each later pass")
+	pflag.StringSlice("retry-resolver", nil, "Recursive resolvers (ip[:port]) to use for retry passes instead of iterative resolution")
+	pflag.Int("mutation-rounds", 2, "Rounds of querying neighbours of discovered selectors (0 disables)")
+	pflag.Int("mutation-radius", 2, "How far numeric and year components of discovered selectors are varied")
+	pflag.Int("mutation-max", 500, "Maximum number of mutated selectors queried per round")
+	pflag.Bool("consistency", false, "Query found selectors on every authoritative name server and report disagreements")
+	pflag.StringSlice("public-resolvers", nil, "Public resolvers (ip[:port]) to include in the consistency check")
+	pflag.Bool("ignore-rfc8020", false, "Scan even when _domainkey returns NXDOMAIN (for servers that break RFC 8020)")
+	pflag.String("zone-file", "", "Read DKIM records from a BIND zone file or AXFR dump instead of querying DNS")
+	pflag.String("zone-origin", "", "Origin for relative names in the zone file (defaults to --domain)")
+	pflag.Int("cache-size", dns.DefaultCacheSize, "Entries in the resolution cache shared by all queries of the scan")
 
 	viper.BindPFlags(pflag.CommandLine)
 	pflag.Parse()
@@ -58,6 +91,29 @@ func main() {
 	rulesFile := viper.GetString("rules")
 	quiet := viper.GetBool("quiet")
 	timeout := viper.GetDuration("timeout")
+	zoneFile := viper.GetString("zone-file")
+	minCompleteness := viper.GetFloat64("min-completeness")
+	mutationOpts := mutationOptions{
+		rounds: viper.GetInt("mutation-rounds"),
+		radius: viper.GetInt("mutation-radius"),
+		max:    viper.GetInt("mutation-max"),
+	}
+	retryOpts := dns.RetryOptions{
+		Passes:      viper.GetInt("retry-passes"),
+		Backoff:     viper.GetDuration("retry-backoff"),
+		Workers:     viper.GetInt("retry-workers"),
+		Timeout:     timeout,
+		NameServers: viper.GetStringSlice("retry-resolver"),
+	}
+
+	// Offline mode: no rules, no network access
+	if zoneFile != "" {
+		origin := viper.GetString("zone-origin")
+		if origin == "" {
+			origin = domain
+		}
+		os.Exit(scanZoneFile(zoneFile, origin, quiet))
+	}
 
 	// Validate required flags
 	if domain == "" {
@@ -81,111 +137,112 @@ func main() {
 
 	slog.Info("loaded rules", "count", len(ruleList))
 
-	// Generate selectors
-	selectors := make(map[string]bool)
-	var mu sync.Mutex
-
-	for _, rule := range ruleList {
-		err := generator.GenerateSelectors(rule, domain, func(selector string) {
-			mu.Lock()
-			if !selectors[selector] {
-				selectors[selector] = true
-				slog.Debug("generated selector", "selector", selector, "rule", rule)
-			}
-			mu.Unlock()
-		})
-		if err != nil {
-			slog.Warn("failed to generate selectors from rule", "rule", rule, "error", err)
-		}
+	publicServers, err := dns.PublicServers(viper.GetStringSlice("public-resolvers"))
+	if err != nil {
+		slog.Error("invalid public resolvers", "error", err)
+		os.Exit(1)
 	}
 
-	selectorList := make([]string, 0, len(selectors))
-	for sel := range selectors {
-		selectorList = append(selectorList, sel)
+	// One cache for the whole run: delegations learned by one pass are
+	// reused by every later lookup
+	cache := dns.NewCache(viper.GetInt("cache-size"))
+	retryOpts.Cache = cache
+
+	cfg := &scanConfig{
+		quiet:           quiet,
+		timeout:         timeout,
+		minCompleteness: minCompleteness,
+		ignoreRFC8020:   viper.GetBool("ignore-rfc8020"),
+		consistency:     viper.GetBool("consistency"),
+		publicServers:   publicServers,
+		retry:           retryOpts,
+		mutation:        mutationOpts,
+		cache:           cache,
 	}
 
-	slog.Info("generated selectors", "count", len(selectorList))
+	exitCode := 0
+	if err := scanDomain(context.Background(), domain, ruleList, cfg); err != nil {
+		slog.Error("scan failed", "domain", domain, "error", err)
+		exitCode = 1
+	}
 
-	// Create output formatter
-	formatter := output.NewFormatter(os.Stdout, quiet)
+	stats := cache.Stats()
+	slog.Info("resolution cache", "hits", stats.Hits, "misses", stats.Misses, "writes", stats.Writes, "ejects", stats.Ejects)
+	os.Exit(exitCode)
+}
 
-	// Track found selectors to avoid duplicates
-	foundSelectors := make(map[string]bool)
-	var foundMu sync.Mutex
-
-	// Query DNS
-	ctx := context.Background()
-	resultChan := dns.QuerySelectors(ctx, selectorList, domain, timeout)
-
-	// Process results
-	for result := range resultChan {
-		if result.Error != nil {
-			if !quiet {
-				slog.Debug("DNS query error", "selector", result.Selector, "error", result.Error)
-			}
-			continue
-		}
+// scanZoneFile runs every *._domainkey TXT record of a zone file through the
+// analysis pipeline and returns the process exit code
+func scanZoneFile(path, origin string, quiet bool) int {
+	records, err := zone.ParseFile(path, origin)
+	if err != nil {
+		slog.Error("failed to read zone file", "error", err)
+		return 1
+	}
 
-		if !result.Found {
-			continue
-		}
+	slog.Info("loaded zone file", "path", path, "dkim_records", len(records))
 
-		// Check for duplicate
-		foundMu.Lock()
-		if foundSelectors[result.Selector] {
-			foundMu.Unlock()
-			continue
-		}
-		foundSelectors[result.Selector] = true
-		foundMu.Unlock()
-
-		// Parse DKIM record
-		record, err := dkim.ParseTXT(result.TXT)
-		if err != nil {
-			slog.Debug("failed to parse DKIM record", "selector", result.Selector, "error", err)
-			continue
+	formatter := output.NewFormatter(os.Stdout, quiet)
+	found := 0
+	for _, record := range records {
+		if analyzeRecord(formatter, record.FQDN, record.TXT, record.Domain, record.Selector, output.DiscoveredByZoneFile, nil) {
+			found++
 		}
+	}
 
-		// Check if record has public key
-		if record.PublicKey == "" {
-			continue
-		}
+	if err := formatter.OutputJSON(); err != nil {
+		slog.Error("failed to output JSON", "error", err)
+		return 1
+	}
 
-		// Analyze key
-		keyInfo, err := crypto.AnalyzeKey(record)
-		if err != nil {
-			slog.Debug("failed to analyze key", "selector", result.Selector, "error", err)
-			continue
-		}
+	slog.Info("scan complete", "found", found)
+	return 0
+}
 
-		// Get key bytes for X.509 formatting
-		keyBytes, err := crypto.GetKeyBytes(record)
-		if err != nil {
-			slog.Debug("failed to get key bytes", "selector", result.Selector, "error", err)
-			continue
-		}
+// analyzeRecord parses a DKIM TXT record, analyzes its key and adds it to the
+// formatter. It reports whether a result was added.
+func analyzeRecord(formatter *output.Formatter, fqdn string, txt []string, domain, selector, discoveredBy string, rules []string) bool {
+	// Parse DKIM record
+	record, err := dkim.ParseTXT(txt)
+	if err != nil {
+		slog.Debug("failed to parse DKIM record", "selector", selector, "error", err)
+		return false
+	}
 
-		x509Key := crypto.FormatX509(keyBytes)
+	// Check if record has public key
+	if record.PublicKey == "" {
+		return false
+	}
 
-		// Add result to collection
-		formatter.AddResult(
-			result.FQDN,
-			result.TXT,
-			keyInfo,
-			domain,
-			result.Selector,
-			keyInfo.Mode,
-			x509Key,
-		)
+	// Analyze key
+	keyInfo, err := crypto.AnalyzeKey(record)
+	if err != nil {
+		slog.Debug("failed to analyze key", "selector", selector, "error", err)
+		return false
 	}
 
-	// Output all results as JSON
-	if err := formatter.OutputJSON(); err != nil {
-		slog.Error("failed to output JSON", "error", err)
-		os.Exit(1)
+	// Get key bytes for X.509 formatting
+	keyBytes, err := crypto.GetKeyBytes(record)
+	if err != nil {
+		slog.Debug("failed to get key bytes", "selector", selector, "error", err)
+		return false
 	}
 
-	slog.Info("scan complete", "found", len(foundSelectors))
+	x509Key := crypto.FormatX509(keyBytes)
+
+	// Add result to collection
+	formatter.AddResult(
+		fqdn,
+		txt,
+		keyInfo,
+		domain,
+		selector,
+		keyInfo.Mode,
+		x509Key,
+		discoveredBy,
+		rules,
+	)
+	return true
 }
 
 func parseLogLevel(level string) slog.Level {
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ducksify/panop-tools/dkimizator/internal/consistency"
+	"github.com/ducksify/panop-tools/dkimizator/internal/dns"
+	"github.com/ducksify/panop-tools/dkimizator/internal/generator"
+	"github.com/ducksify/panop-tools/dkimizator/internal/output"
+)
+
+// scanConfig holds the settings shared by the scans of all domains
+type scanConfig struct {
+	quiet           bool
+	timeout         time.Duration
+	minCompleteness float64
+	ignoreRFC8020   bool
+	consistency     bool
+	publicServers   []dns.Server
+	retry           dns.RetryOptions
+	mutation        mutationOptions
+	// cache is shared by every lookup of the scan
+	cache *dns.Cache
+}
+
+// scanDomain queries the selectors generated by the rules for one domain
+// and writes its JSON output
+func scanDomain(ctx context.Context, domain string, ruleList []string, cfg *scanConfig) error {
+	// Generate selectors, remembering every rule that produced each of them
+	selectorRules := make(map[string][]string)
+	var selectorList []string
+	ruleCosts := make([]output.RuleCost, 0, len(ruleList))
+	var mu sync.Mutex
+
+	for _, rule := range ruleList {
+		generated := make(map[string]bool)
+		err := generator.GenerateSelectors(rule, domain, func(selector string) {
+			mu.Lock()
+			if !generated[selector] {
+				generated[selector] = true
+				if _, ok := selectorRules[selector]; !ok {
+					selectorList = append(selectorList, selector)
+				}
+				selectorRules[selector] = append(selectorRules[selector], rule)
+				slog.Debug("generated selector", "selector", selector, "rule", rule)
+			}
+			mu.Unlock()
+		})
+		if err != nil {
+			slog.Warn("failed to generate selectors from rule", "rule", rule, "error", err)
+		}
+		ruleCosts = append(ruleCosts, output.RuleCost{Rule: rule, Selectors: len(generated)})
+	}
+
+	slog.Info("generated selectors", "domain", domain, "count", len(selectorList))
+
+	// Create output formatter
+	formatter := output.NewFormatter(os.Stdout, cfg.quiet)
+	formatter.SetDomain(domain)
+
+	// Track found selectors to avoid duplicates
+	foundSelectors := make(map[string]bool)
+	var foundMu sync.Mutex
+
+	// Final status of every selector, later passes overwrite earlier ones
+	statuses := make(map[string]string, len(selectorList))
+
+	// Live progress on interactive terminals only
+	var progress *output.Progress
+	showProgress := !cfg.quiet && output.IsTerminal(os.Stderr)
+	done, failed, queries := 0, 0, 0
+
+	// Selectors found so far, in discovery order, and the stage finding them
+	var hits []string
+	discoveredBy := output.DiscoveredByRules
+
+	handleResult := func(result *dns.QueryResult) {
+		statuses[result.Selector] = result.Status
+		queries++
+		done++
+		if dns.Retryable(result) {
+			failed++
+		}
+		if progress != nil {
+			progress.Update(done, len(foundSelectors), failed)
+		}
+
+		if result.Error != nil {
+			if !cfg.quiet {
+				slog.Debug("DNS query error", "selector", result.Selector, "error", result.Error)
+			}
+			return
+		}
+
+		if !result.Found {
+			return
+		}
+
+		// Check for duplicate
+		foundMu.Lock()
+		if foundSelectors[result.Selector] {
+			foundMu.Unlock()
+			return
+		}
+		foundSelectors[result.Selector] = true
+		foundMu.Unlock()
+
+		if analyzeRecord(formatter, result.FQDN, result.TXT, domain, result.Selector, discoveredBy, selectorRules[result.Selector]) {
+			hits = append(hits, result.Selector)
+		}
+	}
+
+	start := time.Now()
+
+	// RFC 8020: an authoritative NXDOMAIN for _domainkey means no selector can exist
+	if !cfg.ignoreRFC8020 {
+		namespace, err := dns.CheckNamespace(ctx, domain, dns.Options{Timeout: cfg.timeout, Cache: cfg.cache})
+		if err != nil {
+			slog.Warn("_domainkey pre-flight query failed", "error", err)
+		} else if namespace.Missing() {
+			slog.Info("no DKIM namespace, skipping scan", "fqdn", namespace.FQDN, "status", namespace.Status)
+			summary := &output.Summary{Queries: 1}
+			summary.Finish(time.Since(start), cfg.minCompleteness)
+			formatter.SetNoDKIMNamespace()
+			formatter.SetSummary(summary)
+			return formatter.OutputJSON()
+		} else {
+			slog.Debug("_domainkey pre-flight", "fqdn", namespace.FQDN, "status", namespace.Status)
+		}
+	}
+
+	// Query DNS
+	if showProgress {
+		progress = output.NewProgress(os.Stderr, len(selectorList))
+	}
+	var retrySelectors []string
+	resultChan := dns.QuerySelectors(ctx, selectorList, domain, dns.Options{Timeout: cfg.timeout, Cache: cfg.cache})
+	for result := range resultChan {
+		handleResult(result)
+		if dns.Retryable(result) {
+			retrySelectors = append(retrySelectors, result.Selector)
+		}
+	}
+	if progress != nil {
+		progress.Done()
+	}
+	// Only now were the generated selectors actually queried
+	formatter.SetRuleCosts(ruleCosts)
+
+	// Retry failed and timed-out selectors
+	unresolved := retrySelectors
+	retried := 0
+	if len(retrySelectors) > 0 && cfg.retry.Passes > 0 {
+		retried = len(retrySelectors)
+		done, failed = 0, 0
+		if showProgress {
+			progress = output.NewProgress(os.Stderr, len(retrySelectors))
+		}
+		unresolved = dns.RetrySelectors(ctx, retrySelectors, domain, cfg.retry, handleResult)
+		if progress != nil {
+			progress.Done()
+		}
+	}
+
+	// Adaptive mutation: query the neighbours of every hit, then of the new hits
+	discoveredBy = output.DiscoveredByMutation
+	mutationSource := hits
+	for round := 1; round <= cfg.mutation.rounds && len(mutationSource) > 0; round++ {
+		candidates := mutationCandidates(mutationSource, domain, cfg.mutation, statuses, selectorRules)
+		if len(candidates) == 0 {
+			break
+		}
+		slog.Info("querying mutated selectors", "round", round, "count", len(candidates))
+
+		done, failed = 0, 0
+		if showProgress {
+			progress = output.NewProgress(os.Stderr, len(candidates))
+		}
+		hitsBefore := len(hits)
+		for result := range dns.QuerySelectors(ctx, candidates, domain, dns.Options{Timeout: cfg.timeout, Cache: cfg.cache}) {
+			handleResult(result)
+			// Mutations are not retried, their failures stay unresolved
+			if dns.Retryable(result) {
+				unresolved = append(unresolved, result.Selector)
+			}
+		}
+		if progress != nil {
+			progress.Done()
+		}
+		mutationSource = hits[hitsBefore:]
+	}
+
+	// Compare the found keys across authoritative servers and public resolvers
+	if cfg.consistency && len(hits) > 0 {
+		servers, err := dns.LookupNameServers(ctx, domain, dns.Options{Timeout: cfg.timeout, Cache: cfg.cache})
+		if err != nil {
+			slog.Warn("failed to look up authoritative name servers", "error", err)
+		}
+		servers = append(servers, cfg.publicServers...)
+
+		if len(servers) > 0 {
+			checks := consistency.Check(ctx, domain, hits, servers, cfg.timeout)
+			for _, check := range checks {
+				if !check.Consistent {
+					slog.Warn("inconsistent DKIM record", "fqdn", check.FQDN, "disagreements", len(check.Disagreements))
+				}
+			}
+			formatter.SetConsistency(checks)
+		}
+	}
+
+	summary := &output.Summary{}
+	for _, status := range statuses {
+		summary.Record(status)
+	}
+	summary.Queries = queries
+	summary.Retried = retried
+	summary.Unresolved = unresolved
+	sort.Strings(summary.Unresolved)
+	summary.Finish(time.Since(start), cfg.minCompleteness)
+	formatter.SetSummary(summary)
+	if !summary.Complete {
+		slog.Warn("scan incomplete", "completeness", summary.Completeness, "threshold", cfg.minCompleteness,
+			"servfail", summary.ServFail, "refused", summary.Refused, "timeout", summary.Timeout, "errors", summary.Errors)
+	}
+
+	// Output all results as JSON
+	if err := formatter.OutputJSON(); err != nil {
+		return err
+	}
+
+	slog.Info("scan complete", "domain", domain, "found", len(foundSelectors), "queries", summary.Queries, "duration", time.Since(start))
+	return nil
+}
+
+// mutationOptions bounds the adaptive mutation stage
+type mutationOptions struct {
+	rounds int
+	radius int
+	max    int
+}
+
+// mutationCandidates expands the mutation rules of every hit into selectors
+// that were not queried yet, up to opts.max. The mutation rule producing each
+// candidate is recorded in selectorRules.
+func mutationCandidates(hits []string, domain string, opts mutationOptions, queried map[string]string, selectorRules map[string][]string) []string {
+	seen := make(map[string]bool)
+	var candidates []string
+
+	for _, hit := range hits {
+		for _, rule := range generator.MutationRules(hit, opts.radius) {
+			err := generator.GenerateSelectors(rule, domain, func(selector string) {
+				if len(candidates) >= opts.max || seen[selector] {
+					return
+				}
+				if _, ok := queried[selector]; ok {
+					return
+				}
+				seen[selector] = true
+				candidates = append(candidates, selector)
+				selectorRules[selector] = []string{rule}
+			})
+			if err != nil {
+				slog.Warn("failed to generate selectors from mutation rule", "rule", rule, "error", err)
+			}
+		}
+	}
+
+	return candidates
+}
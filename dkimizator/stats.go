@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+
+	"github.com/ducksify/panop-tools/dkimizator/internal/stats"
+)
+
+// runStats implements "dkimizator stats [files]..." which aggregates scan
+// outputs into per-rule hit rates and query costs. Reads stdin without files.
+func runStats(args []string) int {
+	fs := pflag.NewFlagSet("stats", pflag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dkimizator stats [scan-output.json]...\n\n")
+		fmt.Fprintln(fs.Output(), "Aggregates dkimizator scan outputs (one JSON document per scan, stdin when no file is given)")
+		fmt.Fprintln(fs.Output(), "into per-rule hit rates and query costs, most productive rules first.")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	collector := stats.NewCollector()
+
+	if fs.NArg() == 0 {
+		if err := collector.AddReader(os.Stdin); err != nil {
+			fmt.Fprintf(os.Stderr, "stdin: %v\n", err)
+			return 1
+		}
+	}
+	for _, path := range fs.Args() {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		err = collector.AddReader(file)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return 1
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(collector.Report()); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to output JSON: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// targetError is the NDJSON record of a target that could not be scanned
+type targetError struct {
+	Scanner string `json:"scanner"`
+	Version string `json:"version"`
+	Target  string `json:"target"`
+	Date    string `json:"date"`
+	Error   string `json:"error"`
+}
+
+// readTargets reads one URL per line from path, or stdin for "-", skipping
+// blank lines and # comments
+func readTargets(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var targets []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		targets = append(targets, line)
+	}
+	return targets, scanner.Err()
+}
+
+// scanTarget runs the scan of one target of a batch, turning a panic into an
+// error so that the other targets and the output stream survive it
+func scanTarget(opts cliOptions, db *db, log *logger) (rep *report, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	// Clients carry per-scan settings, only the DB is shared
+	client := newHTTPClient(opts.timeout, log)
+	return runScan(opts, client, db, log)
+}
+
+// runBatch scans targets concurrently with the same DB and writes one
+// report, or error record, per line to out as each target completes. It
+// returns the number of targets that failed.
+func runBatch(targets []string, opts cliOptions, db *db, info *dbInfo, out io.Writer, log *logger) int {
+	workers := opts.targetConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(targets) {
+		workers = len(targets)
+	}
+
+	var mu sync.Mutex
+	enc := json.NewEncoder(out)
+	failed := 0
+	write := func(v any) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := enc.Encode(v); err != nil {
+			log.Debugf("failed to write record: %v", err)
+		}
+	}
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range jobs {
+				targetOpts := opts
+				targetOpts.targetURL = target
+
+				report, err := scanTarget(targetOpts, db, log)
+				if err != nil {
+					log.Debugf("Target %s failed: %v", target, err)
+					mu.Lock()
+					failed++
+					mu.Unlock()
+					write(targetError{
+						Scanner: "jsaudit-go",
+						Version: "1.0.0",
+						Target:  target,
+						Date:    time.Now().UTC().Format(time.RFC3339),
+						Error:   err.Error(),
+					})
+					continue
+				}
+				report.DB = info
+
+				if opts.format == "sarif" {
+					write(toSARIF(report))
+				} else {
+					write(report)
+				}
+			}
+		}()
+	}
+	for _, target := range targets {
+		jobs <- target
+	}
+	close(jobs)
+	wg.Wait()
+
+	return failed
+}
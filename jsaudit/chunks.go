@@ -0,0 +1,186 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// import("./chunk.js"), the target of code splitting in native ES modules
+	dynamicImportRe = regexp.MustCompile(`\bimport\(\s*["'` + "`" + `]([^"'` + "`" + `\s]+\.m?js)["'` + "`" + `]\s*\)`)
+	// __webpack_require__.u = function(chunkId) { return ... }, r.u=e=>... or
+	// (chunkId) => { return ... }, with comments allowed before the return
+	webpackChunkURLRe = regexp.MustCompile(`\.u\s*=\s*(?:function\s*\(\s*(\w+)\s*\)\s*|\(?\s*(\w+)\s*\)?\s*=>\s*)(?:\{\s*(?://[^\n]*\s*)*return\s*|\(?)((?:[^;}]|\}\s*\[)*?\.js["'])`)
+	// {id:"value"}[param], or ({id:"value"}[param]||param) with a fallback
+	chunkLookupRe = regexp.MustCompile(`^\(?\s*\{([^{}]*)\}\s*\[\s*(\w+)\s*\]\s*(?:(\|\|)\s*(\w+)\s*)?\)?$`)
+	// __webpack_require__.p = "/static/"
+	webpackPublicPathRe = regexp.MustCompile(`\.p\s*=\s*["']([^"']*)["']`)
+	// Vite: __vite__mapDeps file lists and __vitePreload dependency arrays
+	viteDepsRe    = regexp.MustCompile(`(?:m\.f\s*\|\|\s*\(\s*m\.f\s*=\s*|__vitePreload\([^\[]{0,200}?,\s*)\[([^\]]*)\]`)
+	stringLitRe   = regexp.MustCompile(`"([^"]*)"|'([^']*)'`)
+	objectEntryRe = regexp.MustCompile(`(?:"([^"]+)"|'([^']+)'|([\w$]+))\s*:\s*(?:"([^"]*)"|'([^']*)')`)
+)
+
+// extractChunkURLs statically finds the chunks a script may load at run time:
+// dynamic imports, webpack chunk URL functions and Vite preload lists.
+func extractChunkURLs(scriptURL, pageURL, body string) []string {
+	script, err := url.Parse(scriptURL)
+	if err != nil {
+		return nil
+	}
+	page, err := url.Parse(pageURL)
+	if err != nil {
+		page = script
+	}
+
+	seen := make(map[string]struct{})
+	var urls []string
+
+	for _, m := range dynamicImportRe.FindAllStringSubmatch(body, -1) {
+		addResolvedURL(m[1], script, seen, &urls)
+	}
+
+	// webpack resolves chunk names against its public path, which is relative
+	// to the page; "auto" (no assignment) means next to the running script
+	publicBase := script
+	if m := webpackPublicPathRe.FindStringSubmatch(body); m != nil {
+		if u, err := url.Parse(m[1]); err == nil {
+			publicBase = page.ResolveReference(u)
+		}
+	}
+	for _, m := range webpackChunkURLRe.FindAllStringSubmatch(body, -1) {
+		param := m[1]
+		if param == "" {
+			param = m[2]
+		}
+		for _, name := range expandWebpackChunkNames(m[3], param) {
+			addResolvedURL(name, publicBase, seen, &urls)
+		}
+	}
+
+	// Vite lists dependencies relative to its base, "/" unless configured
+	root := page.ResolveReference(&url.URL{Path: "/"})
+	for _, m := range viteDepsRe.FindAllStringSubmatch(body, -1) {
+		for _, lit := range stringLitRe.FindAllStringSubmatch(m[1], -1) {
+			dep := lit[1] + lit[2]
+			if !strings.HasSuffix(dep, ".js") && !strings.HasSuffix(dep, ".mjs") {
+				continue
+			}
+			base := root
+			if strings.HasPrefix(dep, ".") {
+				base = script
+			}
+			addResolvedURL(dep, base, seen, &urls)
+		}
+	}
+
+	return urls
+}
+
+// expandWebpackChunkNames evaluates the body of a webpack chunk URL function,
+// a "+" concatenation of string literals, the chunk id parameter and
+// {id:"value"}[param] lookups, for every chunk id listed in the lookups.
+func expandWebpackChunkNames(expr, param string) []string {
+	parts := splitConcat(expr)
+
+	type lookup struct {
+		values   map[string]string
+		fallback bool // ({...}[e]||e)
+	}
+	lookups := make(map[int]lookup)
+	ids := make(map[string]struct{})
+
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		parts[i] = part
+		m := chunkLookupRe.FindStringSubmatch(part)
+		if m == nil || m[2] != param || (m[3] != "" && m[4] != param) {
+			continue
+		}
+		values := make(map[string]string)
+		for _, e := range objectEntryRe.FindAllStringSubmatch(m[1], -1) {
+			values[e[1]+e[2]+e[3]] = e[4] + e[5]
+		}
+		lookups[i] = lookup{values: values, fallback: m[3] != ""}
+		for id := range values {
+			ids[id] = struct{}{}
+		}
+	}
+	if len(lookups) == 0 {
+		return nil
+	}
+
+	sortedIDs := make([]string, 0, len(ids))
+	for id := range ids {
+		sortedIDs = append(sortedIDs, id)
+	}
+	sort.Strings(sortedIDs)
+
+	var names []string
+	for _, id := range sortedIDs {
+		var b strings.Builder
+		ok := true
+		for i, part := range parts {
+			if l, isLookup := lookups[i]; isLookup {
+				v, found := l.values[id]
+				if !found {
+					if !l.fallback {
+						ok = false
+						break
+					}
+					v = id
+				}
+				b.WriteString(v)
+				continue
+			}
+			if m := stringLitRe.FindStringSubmatch(part); m != nil && len(m[0]) == len(part) {
+				b.WriteString(m[1] + m[2])
+				continue
+			}
+			if strings.Trim(part, "() ") == param {
+				b.WriteString(id)
+				continue
+			}
+			// Anything else (function calls, other variables) cannot be evaluated
+			ok = false
+			break
+		}
+		if ok {
+			names = append(names, b.String())
+		}
+	}
+	return names
+}
+
+// splitConcat splits an expression on the "+" operators outside of strings,
+// braces and brackets
+func splitConcat(expr string) []string {
+	var parts []string
+	depth := 0
+	var quote byte
+	start := 0
+
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '{' || c == '[' || c == '(':
+			depth++
+		case c == '}' || c == ']' || c == ')':
+			depth--
+		case c == '+' && depth <= 0:
+			parts = append(parts, expr[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, expr[start:])
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractChunkURLs(t *testing.T) {
+	const (
+		script = "https://ex.com/static/js/main.js"
+		page   = "https://ex.com/shop/"
+	)
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			"webpack 5 minified",
+			`r.p="/static/js/",r.u=e=>"chunk-"+e+"."+{12:"ab12",34:"cd34"}[e]+".js";`,
+			[]string{"https://ex.com/static/js/chunk-12.ab12.js", "https://ex.com/static/js/chunk-34.cd34.js"},
+		},
+		{
+			"webpack 5 unminified",
+			`__webpack_require__.u = (chunkId) => {
+	// return url for filenames based on template
+	return "" + chunkId + "." + {"12":"ab12"}[chunkId] + ".js";
+};`,
+			[]string{"https://ex.com/static/js/12.ab12.js"},
+		},
+		{
+			"webpack 4 function",
+			`__webpack_require__.u = function(chunkId) { return "static/js/" + chunkId + "." + {"7":"ef56"}[chunkId] + ".chunk.js"; };__webpack_require__.p = "/";`,
+			[]string{"https://ex.com/static/js/7.ef56.chunk.js"},
+		},
+		{
+			"named chunks with fallback",
+			`o.u=e=>"js/"+({45:"vendors",67:"admin"}[e]||e)+"."+{45:"1a",67:"2b",89:"3c"}[e]+".js"`,
+			[]string{"https://ex.com/static/js/js/vendors.1a.js", "https://ex.com/static/js/js/admin.2b.js", "https://ex.com/static/js/js/89.3c.js"},
+		},
+		{
+			"unevaluable part",
+			`r.u=e=>getPrefix()+e+"."+{1:"aa"}[e]+".js"`,
+			nil,
+		},
+		{
+			"dynamic import",
+			`const m = await import("./lazy.js"); import('../shared/util.mjs')`,
+			[]string{"https://ex.com/static/js/lazy.js", "https://ex.com/static/shared/util.mjs"},
+		},
+		{
+			"vite mapDeps",
+			`const __vite__mapDeps=(i,m=__vite__mapDeps,d=(m.f||(m.f=["assets/Home-B1x2.js","assets/Home-C3y4.css","./About-D5z6.js"])))=>i.map(i=>d[i]);`,
+			[]string{"https://ex.com/assets/Home-B1x2.js", "https://ex.com/static/js/About-D5z6.js"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := extractChunkURLs(script, page, tt.body); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: extractChunkURLs = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
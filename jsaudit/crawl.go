@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// How a crawled page was discovered, reported in crawledPage.Via
+const (
+	viaTarget  = "target"
+	viaLink    = "link"
+	viaSitemap = "sitemap"
+)
+
+// maxSitemaps bounds the sitemaps read from a sitemap index
+const maxSitemaps = 5
+
+// crawledPage is a page whose scripts were collected
+type crawledPage struct {
+	URL     string `json:"url"`
+	Depth   int    `json:"depth"`
+	Via     string `json:"via"`
+	Status  string `json:"status"`
+	Scripts int    `json:"scripts"`
+	WAF     string `json:"waf,omitempty"`
+
+	page   *page
+	header http.Header
+}
+
+// Resources linked from pages that are never HTML documents
+var nonPageExtensions = map[string]bool{
+	".7z": true, ".avi": true, ".css": true, ".csv": true, ".doc": true, ".docx": true,
+	".exe": true, ".gif": true, ".gz": true, ".ico": true, ".jpeg": true, ".jpg": true,
+	".js": true, ".json": true, ".mjs": true, ".mov": true, ".mp3": true, ".mp4": true,
+	".pdf": true, ".png": true, ".ppt": true, ".pptx": true, ".rar": true, ".svg": true,
+	".tar": true, ".txt": true, ".webm": true, ".webp": true, ".woff": true, ".woff2": true,
+	".xls": true, ".xlsx": true, ".xml": true, ".zip": true,
+}
+
+// crawlPages fetches the target page and, when opts.crawlDepth > 0, the
+// same-origin pages reachable from it through links and sitemap.xml, breadth
+// first, until the depth or the page budget is exhausted. Only a failure of
+// the target page is an error.
+func crawlPages(opts cliOptions, client *httpClient, log *logger) ([]*crawledPage, error) {
+	target, err := url.Parse(opts.targetURL)
+	if err != nil {
+		return nil, err
+	}
+
+	budget := 1
+	if opts.crawlDepth > 0 {
+		budget = opts.maxPages
+	}
+
+	type queued struct {
+		url     string
+		referer string
+		depth   int
+		via     string
+	}
+	queue := []queued{{url: opts.targetURL, depth: 0, via: viaTarget}}
+	seen := map[string]struct{}{normalizePageURL(target): {}}
+	enqueue := func(raw, referer string, depth int, via string) {
+		u, err := url.Parse(raw)
+		if err != nil || !sameOrigin(target, u) || nonPageExtensions[strings.ToLower(path.Ext(u.Path))] {
+			return
+		}
+		key := normalizePageURL(u)
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+		queue = append(queue, queued{url: raw, referer: referer, depth: depth, via: via})
+	}
+
+	var pages []*crawledPage
+	for len(queue) > 0 && len(pages) < budget {
+		q := queue[0]
+		queue = queue[1:]
+
+		cp := &crawledPage{URL: q.url, Depth: q.depth, Via: q.via}
+		pages = append(pages, cp)
+
+		res, err := client.fetch(q.url, q.referer)
+		if err != nil {
+			cp.Status = "failed"
+			var se *statusError
+			if errors.As(err, &se) {
+				if v, blocked := detectBlock(se.res, true); blocked {
+					cp.blocked(v)
+				}
+			}
+			if q.via == viaTarget {
+				return nil, cp.fetchError(err)
+			}
+			log.Debugf("Page %s not fetched: %v", q.url, err)
+			continue
+		}
+		if v, blocked := detectBlock(res, true); blocked {
+			cp.blocked(v)
+			if q.via == viaTarget {
+				return nil, cp.fetchError(nil)
+			}
+			log.Debugf("Page %s blocked: %s", q.url, v.reason)
+			continue
+		}
+
+		// Links are compared with, and resolved against, the page redirects
+		// led to: http to https or apex to www would otherwise lose them all
+		if final, err := url.Parse(res.url); err == nil && res.url != q.url {
+			key := normalizePageURL(final)
+			_, crawled := seen[key]
+			switch {
+			case q.via == viaTarget:
+				target = final
+			case !sameOrigin(target, final) || crawled:
+				log.Debugf("Page %s skipped: redirected to %s", q.url, res.url)
+				cp.Status = "skipped"
+				continue
+			}
+			seen[key] = struct{}{}
+			cp.URL = res.url
+		}
+		if ct := res.header.Get("Content-Type"); ct != "" && !strings.Contains(strings.ToLower(ct), "html") && q.via != viaTarget {
+			log.Debugf("Page %s skipped: %s", q.url, ct)
+			cp.Status = "skipped"
+			continue
+		}
+
+		pg, err := parsePage(res.body, cp.URL)
+		if err != nil {
+			if q.via == viaTarget {
+				return nil, err
+			}
+			cp.Status = "failed"
+			continue
+		}
+		cp.Status = "ok"
+		cp.page = pg
+		cp.header = res.header
+		cp.Scripts = len(pg.external) + len(pg.inline)
+
+		if q.depth >= opts.crawlDepth {
+			continue
+		}
+		for _, link := range pg.links {
+			enqueue(link, cp.URL, q.depth+1, viaLink)
+		}
+		if q.via == viaTarget {
+			for _, loc := range fetchSitemap(target, client, log) {
+				enqueue(loc, "", 1, viaSitemap)
+			}
+		}
+	}
+
+	log.Debugf("Crawled %d pages, %d left in queue", len(pages), len(queue))
+	return pages, nil
+}
+
+func (cp *crawledPage) blocked(v blockVerdict) {
+	cp.Status = "blocked"
+	cp.WAF = v.vendor
+}
+
+// fetchError describes why a page could not be scanned
+func (cp *crawledPage) fetchError(err error) error {
+	if cp.Status != "blocked" {
+		return err
+	}
+	if cp.WAF != "" {
+		return fmt.Errorf("%s blocked by %s", cp.URL, cp.WAF)
+	}
+	return fmt.Errorf("%s blocked", cp.URL)
+}
+
+type sitemap struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// fetchSitemap returns the page URLs listed in /sitemap.xml of the target's
+// origin, following a sitemap index one level deep
+func fetchSitemap(target *url.URL, client *httpClient, log *logger) []string {
+	root := target.ResolveReference(&url.URL{Path: "/sitemap.xml"})
+
+	var locs []string
+	pending := []string{root.String()}
+	for read := 0; len(pending) > 0 && read <= maxSitemaps; read++ {
+		sitemapURL := pending[0]
+		pending = pending[1:]
+
+		body, err := client.fetchText(sitemapURL, "")
+		if err != nil {
+			log.Debugf("Sitemap %s not fetched: %v", sitemapURL, err)
+			continue
+		}
+
+		var sm sitemap
+		if err := xml.Unmarshal([]byte(body), &sm); err != nil {
+			log.Debugf("Sitemap %s is not valid XML: %v", sitemapURL, err)
+			continue
+		}
+		for _, u := range sm.URLs {
+			locs = append(locs, strings.TrimSpace(u.Loc))
+		}
+		// Only the root may be an index
+		if sitemapURL == root.String() {
+			for _, s := range sm.Sitemaps {
+				loc := strings.TrimSpace(s.Loc)
+				if u, err := url.Parse(loc); err == nil && sameOrigin(target, u) {
+					pending = append(pending, loc)
+				}
+			}
+		}
+	}
+
+	log.Debugf("Sitemap lists %d URLs", len(locs))
+	return locs
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host)
+}
+
+// normalizePageURL identifies a page regardless of fragment and host case
+func normalizePageURL(u *url.URL) string {
+	n := *u
+	n.Fragment = ""
+	n.RawFragment = ""
+	n.Host = strings.ToLower(n.Host)
+	if n.Path == "" {
+		n.Path = "/"
+	}
+	return n.String()
+}
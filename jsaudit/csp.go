@@ -0,0 +1,397 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Where a policy was declared, reported in cspPolicy.Source
+const (
+	cspHeader           = "header"
+	cspHeaderReportOnly = "header-report-only"
+	cspMeta             = "meta"
+)
+
+// cspPolicy is one Content-Security-Policy of the target page
+type cspPolicy struct {
+	Source        string   `json:"source"`
+	Policy        string   `json:"policy"`
+	ScriptSources []string `json:"script_sources,omitempty"` // effective script-src
+	Directive     string   `json:"directive,omitempty"`      // script-src or default-src
+
+	directives map[string][]string
+}
+
+// cspJSONPHosts host JSONP endpoints or arbitrary versions of script gadgets
+// (old AngularJS, etc.), which turn an allow-list entry into a CSP bypass
+var cspJSONPHosts = []string{
+	"accounts.google.com",
+	"ajax.googleapis.com",
+	"apis.google.com",
+	"cdn.jsdelivr.net",
+	"cdnjs.cloudflare.com",
+	"code.jquery.com",
+	"www.google.com",
+	"www.googleapis.com",
+	"www.googletagmanager.com",
+	"www.youtube.com",
+	"graph.facebook.com",
+	"connect.facebook.net",
+	"api.twitter.com",
+	"unpkg.com",
+}
+
+// collectCSP returns the policies of a page, from its response headers and
+// <meta http-equiv> tags. A header may carry several comma-separated
+// policies; a meta tag holds exactly one.
+func collectCSP(header http.Header, meta []string) []cspPolicy {
+	var policies []cspPolicy
+	add := func(source, raw string) {
+		if raw = strings.TrimSpace(raw); raw != "" {
+			policies = append(policies, parseCSP(source, raw))
+		}
+	}
+	for _, v := range header.Values("Content-Security-Policy") {
+		for _, p := range strings.Split(v, ",") {
+			add(cspHeader, p)
+		}
+	}
+	for _, v := range header.Values("Content-Security-Policy-Report-Only") {
+		for _, p := range strings.Split(v, ",") {
+			add(cspHeaderReportOnly, p)
+		}
+	}
+	for _, v := range meta {
+		add(cspMeta, v)
+	}
+	return policies
+}
+
+// parseCSP splits a serialized policy into directives; the first occurrence
+// of a directive wins
+func parseCSP(source, raw string) cspPolicy {
+	p := cspPolicy{Source: source, Policy: raw, directives: make(map[string][]string)}
+	for _, d := range strings.Split(raw, ";") {
+		fields := strings.Fields(d)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+		if _, dup := p.directives[name]; !dup {
+			p.directives[name] = fields[1:]
+		}
+	}
+	for _, name := range []string{"script-src", "default-src"} {
+		if sources, ok := p.directives[name]; ok {
+			p.Directive = name
+			p.ScriptSources = sources
+			break
+		}
+	}
+	return p
+}
+
+// hasKeyword reports whether a quoted keyword source ('self', ...) is listed
+func (p cspPolicy) hasKeyword(keyword string) bool {
+	for _, s := range p.ScriptSources {
+		if strings.EqualFold(s, "'"+keyword+"'") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPrefixSource reports whether a nonce or hash source is listed
+func (p cspPolicy) hasPrefixSource(prefixes ...string) bool {
+	for _, s := range p.ScriptSources {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(strings.ToLower(s), "'"+prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowsExternal reports whether the policy lets the page load a script.
+// Nonces and hashes (of the integrity attribute) allow any URL; with
+// 'strict-dynamic' they are the only way for a tag, while scripts inserted
+// by an allowed script (chunks) are trusted.
+func (p cspPolicy) allowsExternal(page, script *url.URL, attrs *scriptAttrs, chunk bool) bool {
+	if p.Directive == "" {
+		return true
+	}
+	if attrs != nil && (p.allowsNonce(attrs.nonce) || p.allowsIntegrity(attrs.Integrity)) {
+		return true
+	}
+	if p.hasKeyword("strict-dynamic") {
+		return chunk
+	}
+	for _, s := range p.ScriptSources {
+		if cspSourceMatches(s, page, script) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsInline reports whether the policy lets an inline script run
+func (p cspPolicy) allowsInline(body string, attrs scriptAttrs) bool {
+	if p.Directive == "" {
+		return true
+	}
+	if p.allowsNonce(attrs.nonce) {
+		return true
+	}
+	sum256 := sha256.Sum256([]byte(body))
+	sum384 := sha512.Sum384([]byte(body))
+	sum512 := sha512.Sum512([]byte(body))
+	for _, h := range []string{
+		"sha256-" + base64.StdEncoding.EncodeToString(sum256[:]),
+		"sha384-" + base64.StdEncoding.EncodeToString(sum384[:]),
+		"sha512-" + base64.StdEncoding.EncodeToString(sum512[:]),
+	} {
+		if p.hasSource("'" + h + "'") {
+			return true
+		}
+	}
+	return p.unsafeInline()
+}
+
+// unsafeInline reports whether any inline script may run. 'unsafe-inline'
+// is ignored when a nonce, a hash or 'strict-dynamic' is present.
+func (p cspPolicy) unsafeInline() bool {
+	return p.hasKeyword("unsafe-inline") && !p.hasPrefixSource("nonce-", "sha256-", "sha384-", "sha512-") && !p.hasKeyword("strict-dynamic")
+}
+
+func (p cspPolicy) allowsNonce(nonce string) bool {
+	return nonce != "" && p.hasSource("'nonce-"+nonce+"'")
+}
+
+func (p cspPolicy) allowsIntegrity(integrity string) bool {
+	for _, token := range strings.Fields(integrity) {
+		token, _, _ = strings.Cut(token, "?")
+		if p.hasSource("'" + token + "'") {
+			return true
+		}
+	}
+	return false
+}
+
+func (p cspPolicy) hasSource(source string) bool {
+	for _, s := range p.ScriptSources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// cspSourceMatches implements the CSP3 URL matching of 'self', scheme and
+// host sources
+func cspSourceMatches(source string, page, u *url.URL) bool {
+	source = strings.ToLower(source)
+	scheme := strings.ToLower(u.Scheme)
+
+	if source == "'self'" {
+		return strings.EqualFold(u.Host, page.Host) && schemeMatches(strings.ToLower(page.Scheme), scheme)
+	}
+	if strings.HasPrefix(source, "'") {
+		return false
+	}
+	if strings.HasSuffix(source, ":") && !strings.Contains(source, "/") {
+		return schemeMatches(strings.TrimSuffix(source, ":"), scheme)
+	}
+
+	if source == "*" {
+		// * does not cover data:, blob: and other local schemes
+		return scheme == "http" || scheme == "https" || scheme == strings.ToLower(page.Scheme)
+	}
+
+	// host-source: [scheme://]host[:port][/path]
+	rest := source
+	if s, r, ok := strings.Cut(rest, "://"); ok {
+		if !schemeMatches(s, scheme) {
+			return false
+		}
+		rest = r
+	} else if !schemeMatches(strings.ToLower(page.Scheme), scheme) {
+		return false
+	}
+	hostPort, srcPath := rest, ""
+	if i := strings.Index(rest, "/"); i >= 0 {
+		hostPort, srcPath = rest[:i], rest[i:]
+	}
+	host, port, hasPort := strings.Cut(hostPort, ":")
+
+	urlHost := strings.ToLower(u.Hostname())
+	switch {
+	case host == "*":
+	case strings.HasPrefix(host, "*."):
+		if !strings.HasSuffix(urlHost, host[1:]) {
+			return false
+		}
+	case host != urlHost:
+		return false
+	}
+
+	urlPort := u.Port()
+	if urlPort == "" {
+		urlPort = defaultPort(scheme)
+	}
+	switch {
+	case hasPort && port == "*":
+	case hasPort:
+		if port != urlPort {
+			return false
+		}
+	default:
+		if urlPort != defaultPort(scheme) {
+			return false
+		}
+	}
+
+	if srcPath != "" && srcPath != "/" {
+		if strings.HasSuffix(srcPath, "/") {
+			return strings.HasPrefix(u.Path, srcPath)
+		}
+		return u.Path == srcPath
+	}
+	return true
+}
+
+// schemeMatches allows the secure upgrade of an allowed scheme
+func schemeMatches(allowed, scheme string) bool {
+	return allowed == scheme || (allowed == "http" && scheme == "https") || (allowed == "ws" && scheme == "wss")
+}
+
+func defaultPort(scheme string) string {
+	switch scheme {
+	case "http", "ws":
+		return "80"
+	case "https", "wss":
+		return "443"
+	}
+	return ""
+}
+
+// evaluateCSP flags weak or missing policies on the target page and the
+// scripts of that page its policies block
+func evaluateCSP(policies []cspPolicy, pageURL string, scripts []scriptEntry, inline []pageInline) []finding {
+	var findings []finding
+	add := func(typ, severity, file, u, format string, args ...any) {
+		findings = append(findings, finding{
+			Type:        typ,
+			Severity:    severity,
+			File:        file,
+			URL:         u,
+			Description: fmt.Sprintf(format, args...),
+		})
+	}
+
+	page, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+
+	enforced := false
+	for _, p := range policies {
+		if p.Source != cspHeaderReportOnly && p.Directive != "" {
+			enforced = true
+		}
+	}
+	if !enforced {
+		if len(policies) > 0 {
+			add("csp-not-enforced", "LOW", "", pageURL, "No enforced Content-Security-Policy restricts scripts; report-only policies do not block anything")
+		} else {
+			add("csp-missing", "LOW", "", pageURL, "No Content-Security-Policy restricts scripts")
+		}
+	}
+
+	for _, p := range policies {
+		if p.Directive == "" {
+			continue
+		}
+		where := fmt.Sprintf("%s of the %s policy", p.Directive, p.Source)
+		strict := p.hasKeyword("strict-dynamic")
+
+		if p.unsafeInline() {
+			add("csp-unsafe-inline", "MEDIUM", "", pageURL, "'unsafe-inline' in %s allows injected inline scripts", where)
+		}
+		if p.hasKeyword("unsafe-eval") {
+			add("csp-unsafe-eval", "MEDIUM", "", pageURL, "'unsafe-eval' in %s allows eval() and string timers", where)
+		}
+		if strict {
+			// Host and scheme sources are ignored
+			continue
+		}
+		for _, s := range p.ScriptSources {
+			ls := strings.ToLower(s)
+			switch {
+			case ls == "*" || ls == "http:" || ls == "https:":
+				add("csp-wildcard-source", "MEDIUM", "", pageURL, "%s in %s allows scripts from any host", s, where)
+			case ls == "data:":
+				add("csp-wildcard-source", "MEDIUM", "", pageURL, "%s in %s allows data: URL scripts, whose code an injection fully controls", s, where)
+			case ls == "blob:":
+				add("csp-wildcard-source", "MEDIUM", "", pageURL, "%s in %s allows blob: URL scripts, which any script of the page can create from a string", s, where)
+			case strings.Contains(ls, "*."):
+				add("csp-wildcard-host", "LOW", "", pageURL, "%s in %s allows scripts from any subdomain", s, where)
+			}
+			if strings.HasPrefix(ls, "'") || !strings.ContainsAny(ls, ".") {
+				continue
+			}
+			for _, host := range cspJSONPHosts {
+				h := &url.URL{Scheme: page.Scheme, Host: host, Path: "/"}
+				if cspSourceMatches(s, page, h) {
+					add("csp-jsonp-host", "MEDIUM", "", pageURL, "%s in %s allows %s, which serves JSONP endpoints or script gadgets that bypass the policy", s, where, host)
+				}
+			}
+		}
+	}
+
+	// Scripts of the target page the policies would block
+	for _, p := range policies {
+		if p.Directive == "" {
+			continue
+		}
+		verb := "blocks"
+		if p.Source == cspHeaderReportOnly {
+			verb = "reports"
+		}
+		for _, entry := range scripts {
+			if !containsString(entry.Pages, pageURL) {
+				continue
+			}
+			u, err := url.Parse(entry.URL)
+			if err != nil {
+				continue
+			}
+			if !p.allowsExternal(page, u, entry.Attributes, entry.Source == sourceChunk) {
+				add("csp-violation", "LOW", entry.File, entry.URL, "The %s policy %s this script (%s: %s)", p.Source, verb, p.Directive, strings.Join(p.ScriptSources, " "))
+			}
+		}
+		for i, s := range inline {
+			if !containsString(s.pages, pageURL) {
+				continue
+			}
+			if !p.allowsInline(s.body, s.attrs) {
+				add("csp-violation", "LOW", fmt.Sprintf("inline#%d", i+1), pageURL, "The %s policy %s this inline script (%s: %s)", p.Source, verb, p.Directive, strings.Join(p.ScriptSources, " "))
+			}
+		}
+	}
+	return findings
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
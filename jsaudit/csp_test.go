@@ -0,0 +1,166 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSPSourceMatches(t *testing.T) {
+	tests := []struct {
+		source, page, url string
+		want              bool
+	}{
+		{"'self'", "https://ex.com/", "https://ex.com/app.js", true},
+		{"'self'", "https://ex.com/", "https://EX.com/app.js", true},
+		{"'self'", "http://ex.com/", "https://ex.com/app.js", true},
+		{"'self'", "https://ex.com/", "http://ex.com/app.js", false},
+		{"'self'", "https://ex.com/", "https://cdn.ex.com/app.js", false},
+		{"'self'", "https://ex.com/", "https://ex.com:8443/app.js", false},
+		{"'none'", "https://ex.com/", "https://ex.com/app.js", false},
+		{"'nonce-r4nd'", "https://ex.com/", "https://ex.com/app.js", false},
+		{"*", "https://ex.com/", "https://cdn.other.com/app.js", true},
+		{"*", "https://ex.com/", "http://cdn.other.com/app.js", true},
+		{"*", "https://ex.com/", "data:text/javascript,alert(1)", false},
+		{"*", "https://ex.com/", "blob:https://ex.com/0f6e", false},
+		{"https:", "https://ex.com/", "https://cdn.other.com/app.js", true},
+		{"https:", "https://ex.com/", "http://cdn.other.com/app.js", false},
+		{"http:", "https://ex.com/", "https://cdn.other.com/app.js", true},
+		{"data:", "https://ex.com/", "data:text/javascript,alert(1)", true},
+		{"blob:", "https://ex.com/", "blob:https://ex.com/0f6e", true},
+		{"cdn.ex.com", "https://ex.com/", "https://cdn.ex.com/app.js", true},
+		{"CDN.ex.com", "https://ex.com/", "https://cdn.ex.com/app.js", true},
+		{"cdn.ex.com", "https://ex.com/", "http://cdn.ex.com/app.js", false},
+		{"cdn.ex.com", "http://ex.com/", "https://cdn.ex.com/app.js", true},
+		{"cdn.ex.com", "https://ex.com/", "https://static.ex.com/app.js", false},
+		{"http://cdn.ex.com", "https://ex.com/", "https://cdn.ex.com/app.js", true},
+		{"https://cdn.ex.com", "https://ex.com/", "http://cdn.ex.com/app.js", false},
+		{"*.ex.com", "https://ex.com/", "https://a.b.ex.com/app.js", true},
+		{"*.ex.com", "https://ex.com/", "https://ex.com/app.js", false},
+		{"*.ex.com", "https://ex.com/", "https://evilex.com/app.js", false},
+		{"https://*", "https://ex.com/", "https://cdn.other.com/app.js", true},
+		{"cdn.ex.com:8443", "https://ex.com/", "https://cdn.ex.com:8443/app.js", true},
+		{"cdn.ex.com:8443", "https://ex.com/", "https://cdn.ex.com/app.js", false},
+		{"cdn.ex.com", "https://ex.com/", "https://cdn.ex.com:8443/app.js", false},
+		{"cdn.ex.com", "https://ex.com/", "https://cdn.ex.com:443/app.js", true},
+		{"cdn.ex.com:*", "https://ex.com/", "https://cdn.ex.com:8443/app.js", true},
+		{"cdn.ex.com/js/", "https://ex.com/", "https://cdn.ex.com/js/vendor/app.js", true},
+		{"cdn.ex.com/js/", "https://ex.com/", "https://cdn.ex.com/lib/app.js", false},
+		{"cdn.ex.com/js/app.js", "https://ex.com/", "https://cdn.ex.com/js/app.js?v=2", true},
+		{"cdn.ex.com/js/app.js", "https://ex.com/", "https://cdn.ex.com/js/app.min.js", false},
+		{"cdn.ex.com/", "https://ex.com/", "https://cdn.ex.com/any/app.js", true},
+	}
+
+	for _, tt := range tests {
+		page, _ := url.Parse(tt.page)
+		u, _ := url.Parse(tt.url)
+		if got := cspSourceMatches(tt.source, page, u); got != tt.want {
+			t.Errorf("cspSourceMatches(%q, %q, %q) = %v, want %v", tt.source, tt.page, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestCSPAllowsExternal(t *testing.T) {
+	page, _ := url.Parse("https://ex.com/")
+	tests := []struct {
+		policy string
+		url    string
+		attrs  *scriptAttrs
+		chunk  bool
+		want   bool
+	}{
+		{"img-src 'self'", "https://cdn.other.com/app.js", nil, false, true},
+		{"default-src 'self'", "https://ex.com/app.js", nil, false, true},
+		{"default-src 'self'", "https://cdn.other.com/app.js", nil, false, false},
+		{"script-src cdn.other.com; default-src 'self'", "https://cdn.other.com/app.js", nil, false, true},
+		{"script-src 'self' 'nonce-r4nd'", "https://cdn.other.com/app.js", &scriptAttrs{nonce: "r4nd"}, false, true},
+		{"script-src 'self' 'nonce-r4nd'", "https://cdn.other.com/app.js", &scriptAttrs{nonce: "other"}, false, false},
+		{"script-src 'self' 'sha384-abc'", "https://cdn.other.com/app.js", &scriptAttrs{Integrity: "sha256-xyz sha384-abc"}, false, true},
+		{"script-src 'nonce-r4nd' 'strict-dynamic' 'self'", "https://ex.com/app.js", nil, false, false},
+		{"script-src 'nonce-r4nd' 'strict-dynamic'", "https://ex.com/app.js", &scriptAttrs{nonce: "r4nd"}, false, true},
+		{"script-src 'nonce-r4nd' 'strict-dynamic'", "https://cdn.other.com/chunk.js", nil, true, true},
+		{"script-src 'self'", "https://cdn.other.com/chunk.js", nil, true, false},
+	}
+
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		p := parseCSP(cspHeader, tt.policy)
+		if got := p.allowsExternal(page, u, tt.attrs, tt.chunk); got != tt.want {
+			t.Errorf("%q allowsExternal(%q) = %v, want %v", tt.policy, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestCSPAllowsInline(t *testing.T) {
+	const body = "alert(1)"
+	tests := []struct {
+		policy string
+		nonce  string
+		want   bool
+	}{
+		{"script-src 'self'", "", false},
+		{"script-src 'self' 'unsafe-inline'", "", true},
+		{"script-src 'unsafe-inline' 'nonce-r4nd'", "", false},
+		{"script-src 'unsafe-inline' 'nonce-r4nd'", "r4nd", true},
+		{"script-src 'unsafe-inline' 'strict-dynamic'", "", false},
+		{"script-src 'sha256-bhHHL3z2vDgxUt0W3dWQOrprscmda2Y5pLsLg4GF+pI='", "", true},
+		{"script-src 'unsafe-inline' 'sha256-AAAA'", "", false},
+		{"default-src 'none'", "", false},
+		{"img-src 'self'", "", true},
+	}
+
+	for _, tt := range tests {
+		p := parseCSP(cspHeader, tt.policy)
+		if got := p.allowsInline(body, scriptAttrs{nonce: tt.nonce}); got != tt.want {
+			t.Errorf("%q allowsInline(nonce %q) = %v, want %v", tt.policy, tt.nonce, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateCSPWildcardSources(t *testing.T) {
+	policies := []cspPolicy{parseCSP(cspHeader, "script-src 'self' https: data: blob:")}
+	want := map[string]string{
+		"https:": "any host",
+		"data:":  "data: URL scripts",
+		"blob:":  "blob: URL scripts",
+	}
+
+	for _, f := range evaluateCSP(policies, "https://ex.com/", nil, nil) {
+		if f.Type != "csp-wildcard-source" {
+			continue
+		}
+		source, _, _ := strings.Cut(f.Description, " ")
+		if !strings.Contains(f.Description, want[source]) {
+			t.Errorf("%s: %q does not mention %q", source, f.Description, want[source])
+		}
+		delete(want, source)
+	}
+	for source := range want {
+		t.Errorf("%s: no csp-wildcard-source finding", source)
+	}
+}
+
+func TestCollectCSP(t *testing.T) {
+	header := http.Header{
+		"Content-Security-Policy":             {"script-src 'self', default-src 'none'"},
+		"Content-Security-Policy-Report-Only": {"script-src 'none'"},
+	}
+	meta := []string{"script-src 'self' https://a.ex.com, https://b.ex.com"}
+
+	want := []struct{ source, policy string }{
+		{cspHeader, "script-src 'self'"},
+		{cspHeader, "default-src 'none'"},
+		{cspHeaderReportOnly, "script-src 'none'"},
+		{cspMeta, "script-src 'self' https://a.ex.com, https://b.ex.com"},
+	}
+	got := collectCSP(header, meta)
+	if len(got) != len(want) {
+		t.Fatalf("collectCSP returned %d policies, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Source != w.source || got[i].Policy != w.policy {
+			t.Errorf("policy %d = %s %q, want %s %q", i, got[i].Source, got[i].Policy, w.source, w.policy)
+		}
+	}
+}
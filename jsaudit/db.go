@@ -1,13 +1,19 @@
 package main
 
 import (
+	"crypto/sha256"
 	_ "embed"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"strings"
 	"time"
 )
 
@@ -17,11 +23,14 @@ var embeddedRetireDB []byte
 const retireDBURL = "https://raw.githubusercontent.com/RetireJS/retire.js/master/repository/jsrepository-v5.json"
 
 type vuln struct {
-	Below     string `json:"below,omitempty"`
-	AtOrAbove string `json:"atOrAbove,omitempty"`
-	CVE       string `json:"cve"`
-	Severity  string `json:"severity"`
-	Info      string `json:"info,omitempty"`
+	Below      string   `json:"below,omitempty"`
+	AtOrAbove  string   `json:"atOrAbove,omitempty"`
+	ID         string   `json:"id"`
+	CVE        string   `json:"cve,omitempty"`
+	Severity   string   `json:"severity"`
+	Info       string   `json:"info,omitempty"`
+	CWE        []string `json:"cwe,omitempty"`
+	References []string `json:"references,omitempty"`
 }
 
 type libEntry struct {
@@ -29,25 +38,225 @@ type libEntry struct {
 	Vulns []vuln `json:"vulns"`
 }
 
+// hashMatch is the library version of a file identified by its SHA1
+type hashMatch struct {
+	Library string `json:"library"`
+	Version string `json:"version"`
+}
+
+// contentReplace is a RetireJS filecontentreplace extractor: the version is
+// Replacement expanded with the groups matched by Regex
+type contentReplace struct {
+	Regex       string `json:"regex"`
+	Replacement string `json:"replacement"`
+}
+
 type db struct {
-	Libs          map[string]libEntry   `json:"libs"`
-	URLPatterns   map[string][]string   `json:"urlPatterns"`
-	ContentRegex  map[string][]string   `json:"contentPatterns"`
+	Libs             map[string]libEntry         `json:"libs"`
+	FilenamePatterns map[string][]string         `json:"filenamePatterns"`
+	URLPatterns      map[string][]string         `json:"urlPatterns"`
+	ContentRegex     map[string][]string         `json:"contentPatterns"`
+	ContentReplace   map[string][]contentReplace `json:"contentReplace"`
+	Hashes           map[string]hashMatch        `json:"hashes"` // SHA1 hex -> library
+
+	compiled *compiledPatterns
+}
+
+// compiledPatterns are the regexes of the DB patterns, compiled once and
+// shared by every scan. Patterns that do not compile are left out.
+type compiledPatterns struct {
+	filename map[string][]*regexp.Regexp
+	uri      map[string][]*regexp.Regexp
+	content  map[string][]*regexp.Regexp
+	replace  map[string][]compiledReplace
+}
+
+type compiledReplace struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// versionCapture replaces the §§version§§ placeholder of RetireJS patterns
+const versionCapture = `(\d+[\d.]*)`
+
+// compile builds the regexes of every pattern of the DB
+func (d *db) compile(log *logger) {
+	c := &compiledPatterns{
+		filename: make(map[string][]*regexp.Regexp),
+		uri:      make(map[string][]*regexp.Regexp),
+		content:  make(map[string][]*regexp.Regexp),
+		replace:  make(map[string][]compiledReplace),
+	}
+	expand := func(pat string) string {
+		return strings.ReplaceAll(pat, "§§version§§", versionCapture)
+	}
+	compileAll := func(patterns map[string][]string, into map[string][]*regexp.Regexp, compile func(string) (*regexp.Regexp, error)) {
+		for lib, pats := range patterns {
+			for _, pat := range pats {
+				re, err := compile("(?i)" + expand(pat))
+				if err != nil {
+					log.Debugf("Skipping %s pattern %q: %v", lib, pat, err)
+					continue
+				}
+				into[lib] = append(into[lib], re)
+			}
+		}
+	}
+	compileAll(d.FilenamePatterns, c.filename, regexp.Compile)
+	compileAll(d.URLPatterns, c.uri, regexp.Compile)
+	compileAll(d.ContentRegex, c.content, compileRetireRegex)
+	for lib, replacements := range d.ContentReplace {
+		for _, r := range replacements {
+			re, err := compileRetireRegex(expand(r.Regex))
+			if err != nil {
+				log.Debugf("Skipping %s pattern %q: %v", lib, r.Regex, err)
+				continue
+			}
+			c.replace[lib] = append(c.replace[lib], compiledReplace{re: re, replacement: r.Replacement})
+		}
+	}
+	d.compiled = c
+}
+
+const dbFileName = "jsaudit-db.json"
+
+// DB sources reported in dbInfo.Source
+const (
+	dbSourceFlag     = "flag"
+	dbSourceFile     = "file"
+	dbSourceEmbedded = "embedded"
+)
+
+// dbInfo describes where the vulnerability DB in use comes from
+type dbInfo struct {
+	Source    string  `json:"source"`
+	Path      string  `json:"path,omitempty"`
+	UpdatedAt string  `json:"updated_at,omitempty"`
+	AgeDays   float64 `json:"age_days,omitempty"`
+	SHA256    string  `json:"sha256"`
+	Libraries int     `json:"libraries"`
+
+	updated time.Time
+}
+
+// age returns how old the DB is, and false when that is unknown
+func (i dbInfo) age() (time.Duration, bool) {
+	if i.updated.IsZero() {
+		return 0, false
+	}
+	return time.Since(i.updated), true
 }
 
-func loadDB(log *logger) (*db, error) {
+// loadDB loads the vulnerability DB from path when set, otherwise from the
+// newest refreshed copy on disk, otherwise from the embedded copy.
+func loadDB(path string, log *logger) (*db, *dbInfo, error) {
+	if path != "" {
+		return loadDBFile(path, dbSourceFlag, log)
+	}
+
+	var newest string
+	var newestTime time.Time
+	for _, candidate := range dbPaths() {
+		st, err := os.Stat(candidate)
+		if err != nil {
+			continue
+		}
+		if newest == "" || st.ModTime().After(newestTime) {
+			newest, newestTime = candidate, st.ModTime()
+		}
+	}
+	if newest != "" {
+		d, info, err := loadDBFile(newest, dbSourceFile, log)
+		if err == nil {
+			return d, info, nil
+		}
+		fmt.Fprintf(os.Stderr, "warning: ignoring DB %s: %v\n", newest, err)
+	}
+
 	data := embeddedRetireDB
 
 	if len(data) == 0 {
-		return nil, errors.New("embedded DB is empty")
+		return nil, nil, errors.New("embedded DB is empty")
+	}
+
+	info := &dbInfo{Source: dbSourceEmbedded, updated: buildTime()}
+	d, err := parseRetireDB(data, info, log)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse embedded RetireJS DB: %w", err)
+	}
+	return d, info, nil
+}
+
+func loadDBFile(path, source string, log *logger) (*db, *dbInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// The embedded file is the raw RetireJS DB; convert to our internal format.
+	info := &dbInfo{Source: source, Path: path, updated: st.ModTime()}
+	d, err := parseRetireDB(data, info, log)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse RetireJS DB %s: %w", path, err)
+	}
+	log.Debugf("Loaded DB from %s", path)
+	return d, info, nil
+}
+
+// parseRetireDB converts a raw RetireJS DB and completes its provenance
+func parseRetireDB(data []byte, info *dbInfo, log *logger) (*db, error) {
+	// The file is the raw RetireJS DB; convert to our internal format.
 	var raw map[string]any
 	if err := json.Unmarshal(data, &raw); err != nil {
-		return nil, fmt.Errorf("parse embedded RetireJS DB: %w", err)
+		return nil, err
+	}
+	d, err := convertRetireDB(raw, log)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	info.SHA256 = hex.EncodeToString(sum[:])
+	info.Libraries = len(d.Libs)
+	if age, ok := info.age(); ok {
+		info.UpdatedAt = info.updated.UTC().Format(time.RFC3339)
+		info.AgeDays = math.Round(age.Hours()/24*10) / 10
+	}
+	return d, nil
+}
+
+// dbPaths lists where refreshed DBs are stored: next to the executable and
+// in the user cache directory
+func dbPaths() []string {
+	var paths []string
+	if exe, err := os.Executable(); err == nil {
+		paths = append(paths, filepath.Join(filepath.Dir(exe), dbFileName))
 	}
-	return convertRetireDB(raw, log)
+	if dir, err := os.UserCacheDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "jsaudit", dbFileName))
+	}
+	return paths
+}
+
+// buildTime approximates the age of the embedded DB with the time of the
+// commit the binary was built from, zero when unknown
+func buildTime() time.Time {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return time.Time{}
+	}
+	for _, setting := range bi.Settings {
+		if setting.Key == "vcs.time" {
+			t, err := time.Parse(time.RFC3339, setting.Value)
+			if err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
 }
 
 func refreshDB(log *logger, timeout time.Duration) error {
@@ -80,34 +289,57 @@ func refreshDB(log *logger, timeout time.Duration) error {
 
 	// We store the raw RetireJS DB, same format as upstream, so the embedded
 	// file and on-disk file share the same schema.
-	exe, err := os.Executable()
+	data, err := json.MarshalIndent(raw, "", "  ")
 	if err != nil {
 		return err
 	}
-	dir := filepath.Dir(exe)
-	path := filepath.Join(dir, "jsaudit-db.json")
 
-	f, err := os.Create(path)
+	// Next to the executable when writable, otherwise in the cache dir
+	var lastErr error
+	for _, path := range dbPaths() {
+		if err := writeFileAtomic(path, data); err != nil {
+			log.Debugf("Cannot write DB to %s: %v", path, err)
+			lastErr = err
+			continue
+		}
+		log.Debugf("DB refreshed and written to %s", path)
+		return nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no location to store the DB")
+	}
+	return lastErr
+}
+
+// writeFileAtomic replaces path so readers never see a partial DB
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), dbFileName+".*")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	defer os.Remove(tmp.Name())
 
-	enc := json.NewEncoder(f)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(raw); err != nil {
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
 		return err
 	}
-
-	log.Debugf("DB refreshed and written to %s", path)
-	return nil
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
 
 func convertRetireDB(raw map[string]any, log *logger) (*db, error) {
 	result := &db{
-		Libs:         make(map[string]libEntry),
-		URLPatterns:  make(map[string][]string),
-		ContentRegex: make(map[string][]string),
+		Libs:             make(map[string]libEntry),
+		FilenamePatterns: make(map[string][]string),
+		URLPatterns:      make(map[string][]string),
+		ContentRegex:     make(map[string][]string),
+		ContentReplace:   make(map[string][]contentReplace),
+		Hashes:           make(map[string]hashMatch),
 	}
 
 	for key, v := range raw {
@@ -134,31 +366,45 @@ func convertRetireDB(raw map[string]any, log *logger) (*db, error) {
 					continue
 				}
 				id, _ := vm["identifiers"].(map[string]any)
-				cvesAny, _ := id["CVE"].([]any)
-				if len(cvesAny) == 0 {
-					continue
-				}
 				summary, _ := id["summary"].(string)
 
 				below, _ := vm["below"].(string)
 				atOrAbove, _ := vm["atOrAbove"].(string)
+				if err := validateRange(atOrAbove, below); err != nil {
+					log.Debugf("Skipping %s advisory with %v: %s", key, err, summary)
+					continue
+				}
 				severity, _ := vm["severity"].(string)
 				if severity == "" {
 					severity = "MEDIUM"
 				}
 
-				for _, c := range cvesAny {
-					cve, ok := c.(string)
-					if !ok || cve == "" {
+				base := vuln{
+					Below:      below,
+					AtOrAbove:  atOrAbove,
+					Severity:   severity,
+					Info:       summary,
+					CWE:        stringList(vm["cwe"]),
+					References: stringList(vm["info"]),
+				}
+
+				// One entry per CVE; advisories without a CVE are kept under
+				// their best other identifier
+				cves := stringList(id["CVE"])
+				if len(cves) == 0 {
+					base.ID = advisoryID(id)
+					if base.ID == "" {
+						log.Debugf("Skipping %s advisory without identifiers: %s", key, summary)
 						continue
 					}
-					vulns = append(vulns, vuln{
-						Below:     below,
-						AtOrAbove: atOrAbove,
-						CVE:       cve,
-						Severity:  severity,
-						Info:      summary,
-					})
+					vulns = append(vulns, base)
+					continue
+				}
+				for _, cve := range cves {
+					v := base
+					v.ID = cve
+					v.CVE = cve
+					vulns = append(vulns, v)
 				}
 			}
 		}
@@ -173,7 +419,7 @@ func convertRetireDB(raw map[string]any, log *logger) (*db, error) {
 			if filenames, ok := extractors["filename"].([]any); ok {
 				for _, p := range filenames {
 					if s, ok := p.(string); ok && s != "" {
-						result.URLPatterns[key] = append(result.URLPatterns[key], s)
+						result.FilenamePatterns[key] = append(result.FilenamePatterns[key], s)
 					}
 				}
 			}
@@ -191,13 +437,102 @@ func convertRetireDB(raw map[string]any, log *logger) (*db, error) {
 					}
 				}
 			}
+			if replaces, ok := extractors["filecontentreplace"].([]any); ok {
+				for _, p := range replaces {
+					s, _ := p.(string)
+					if r, ok := parseContentReplace(s); ok {
+						result.ContentReplace[key] = append(result.ContentReplace[key], r)
+					}
+				}
+			}
+			if hashes, ok := extractors["hashes"].(map[string]any); ok {
+				for sum, v := range hashes {
+					version, _ := v.(string)
+					if _, err := parseVersion(version); err != nil {
+						log.Debugf("Skipping %s hash %s: %v", key, sum, err)
+						continue
+					}
+					result.Hashes[strings.ToLower(sum)] = hashMatch{Library: key, Version: version}
+				}
+			}
 		}
 	}
 
 	// Add built-in URL/content patterns to match the Node.js implementation.
 	mergeBuiltinPatterns(result)
+	result.compile(log)
 
 	log.Debugf("Loaded %d libraries from RetireJS DB (with builtin patterns)", len(result.Libs))
 	return result, nil
 }
 
+// validateRange checks the bounds of an advisory range
+func validateRange(atOrAbove, below string) error {
+	var lo, hi version
+	var err error
+	if atOrAbove != "" {
+		if lo, err = parseVersion(atOrAbove); err != nil {
+			return err
+		}
+	}
+	if below != "" {
+		if hi, err = parseVersion(below); err != nil {
+			return err
+		}
+	}
+	if atOrAbove != "" && below != "" && lo.compare(hi) >= 0 {
+		return fmt.Errorf("empty range [%s, %s)", atOrAbove, below)
+	}
+	return nil
+}
+
+// parseContentReplace splits a "/regex/replacement/" extractor
+func parseContentReplace(s string) (contentReplace, bool) {
+	if len(s) < 3 || s[0] != '/' || s[len(s)-1] != '/' {
+		return contentReplace{}, false
+	}
+	body := s[1 : len(s)-1]
+	i := strings.LastIndex(body, "/")
+	if i < 0 {
+		return contentReplace{}, false
+	}
+	return contentReplace{Regex: body[:i], Replacement: body[i+1:]}, true
+}
+
+// advisoryIDKeys are the RetireJS identifiers used when there is no CVE, best first
+var advisoryIDKeys = []string{"githubID", "issue", "bug", "retid", "PR", "osvdb", "tenable", "gist", "blog"}
+
+// advisoryID returns the best non-CVE identifier of an advisory. GitHub
+// advisory IDs are globally unique; the others are prefixed with their kind.
+func advisoryID(identifiers map[string]any) string {
+	for _, key := range advisoryIDKeys {
+		values := stringList(identifiers[key])
+		if len(values) == 0 {
+			continue
+		}
+		if key == "githubID" {
+			return values[0]
+		}
+		return key + ":" + values[0]
+	}
+	return ""
+}
+
+// stringList reads a JSON string or list of strings
+func stringList(v any) []string {
+	switch t := v.(type) {
+	case string:
+		if t != "" {
+			return []string{t}
+		}
+	case []any:
+		var out []string
+		for _, item := range t {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// testDB converts a RetireJS DB document like loadDB does
+func testDB(t *testing.T, retire string) *db {
+	t.Helper()
+	var raw map[string]any
+	if err := json.Unmarshal([]byte(retire), &raw); err != nil {
+		t.Fatal(err)
+	}
+	d, err := convertRetireDB(raw, newLogger(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestParseContentReplace(t *testing.T) {
+	tests := []struct {
+		extractor   string
+		ok          bool
+		regex, repl string
+	}{
+		{`/exlib v(§§version§§)/$1/`, true, `exlib v(§§version§§)`, `$1`},
+		{`/exlib\/(\d+)_(\d+)/$1.$2/`, true, `exlib\/(\d+)_(\d+)`, `$1.$2`},
+		{`/VERSION="(\d+[\d.]*)"//`, true, `VERSION="(\d+[\d.]*)"`, ``},
+		{`exlib v(\d+)/$1/`, false, "", ""},
+		{`/exlib/`, false, "", ""},
+		{`//`, false, "", ""},
+		{``, false, "", ""},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseContentReplace(tt.extractor)
+		if ok != tt.ok || got.Regex != tt.regex || got.Replacement != tt.repl {
+			t.Errorf("parseContentReplace(%q) = %+v, %v, want {%s %s}, %v", tt.extractor, got, ok, tt.regex, tt.repl, tt.ok)
+		}
+	}
+}
+
+func TestDetectLibrariesContentReplace(t *testing.T) {
+	d := testDB(t, `{
+		"exlib": {
+			"vulnerabilities": [
+				{"below": "2.5.1", "severity": "high", "identifiers": {"summary": "XSS", "CVE": ["CVE-2020-0001"]}}
+			],
+			"extractors": {
+				"filecontentreplace": [
+					"/exlib\\.v(\\d+)_(\\d+)_(\\d+)/$1.$2.$3/",
+					"/exlib=\\{version:\"(§§version§§)\"/$1/"
+				]
+			}
+		}
+	}`)
+
+	tests := []struct {
+		content    string
+		version    string
+		vulnerable bool
+	}{
+		{`window.exlib.v2_5_0=function(){}`, "2.5.0", true},
+		{`window.exlib.v2_6_0=function(){}`, "2.6.0", false},
+		{`var exlib={version:"2.4.9",init:function(){}}`, "2.4.9", true},
+		{`var other={version:"2.4.9"}`, "", false},
+	}
+
+	for _, tt := range tests {
+		libs := detectLibraries("https://ex.com/app.js", tt.content, d, newLogger(false))
+		if tt.version == "" {
+			if len(libs) != 0 {
+				t.Errorf("%q: detected %+v, want nothing", tt.content, libs)
+			}
+			continue
+		}
+		if len(libs) != 1 {
+			t.Errorf("%q: detected %+v, want exlib %s", tt.content, libs, tt.version)
+			continue
+		}
+		lib := libs[0]
+		if lib.Version != tt.version || lib.Vulnerable != tt.vulnerable || lib.DetectedBy != extractorFileContentReplace {
+			t.Errorf("%q: detected %s %s (vulnerable %v, by %s), want %s (vulnerable %v, by %s)",
+				tt.content, lib.Library, lib.Version, lib.Vulnerable, lib.DetectedBy, tt.version, tt.vulnerable, extractorFileContentReplace)
+		}
+	}
+}
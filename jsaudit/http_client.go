@@ -2,20 +2,21 @@ package main
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"strings"
 	"time"
 )
 
 const userAgent = "Mozilla/5.0 (compatible; Panop/1.0; +https://panop.io/)"
 
 type httpClient struct {
-	client *http.Client
-	retry  int
-	log    *logger
+	client  *http.Client
+	retry   int
+	limiter *hostLimiter // paces every attempt, nil for none
+	log     *logger
 }
 
 func newHTTPClient(timeout time.Duration, log *logger) *httpClient {
@@ -35,24 +36,22 @@ func newHTTPClient(timeout time.Duration, log *logger) *httpClient {
 	}
 }
 
+// sameDomainRedirectOnly follows redirects within a registrable domain, such
+// as apex to www
 func sameDomainRedirectOnly(req *http.Request, via []*http.Request) error {
 	if len(via) == 0 {
 		return nil
 	}
 
 	prev := via[len(via)-1]
-	if !sameHostname(prev.URL, req.URL) {
-		// Keep the redirect response and stop following when host changes.
+	if registrableDomain(prev.URL.Hostname()) != registrableDomain(req.URL.Hostname()) {
+		// Keep the redirect response and stop following when the site changes.
 		return http.ErrUseLastResponse
 	}
 
 	return nil
 }
 
-func sameHostname(a, b *url.URL) bool {
-	return strings.EqualFold(a.Hostname(), b.Hostname())
-}
-
 func (c *httpClient) setRetry(n int) {
 	if n < 0 {
 		n = 0
@@ -60,30 +59,76 @@ func (c *httpClient) setRetry(n int) {
 	c.retry = n
 }
 
+func (c *httpClient) setLimiter(l *hostLimiter) {
+	c.limiter = l
+}
+
+type fetchResult struct {
+	url    string // final URL, after redirects
+	status int
+	body   string
+	header http.Header
+}
+
+// maxErrorBody bounds what is kept of error pages, enough for block detection
+const maxErrorBody = 64 << 10
+
+// statusError is an HTTP error status. It keeps the error page, which is
+// often a WAF block or challenge, and whether retries got different pages.
+type statusError struct {
+	res           *fetchResult
+	retriesDiffer bool
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("HTTP %d", e.res.status)
+}
+
 func (c *httpClient) fetchText(rawURL string, referer string) (string, error) {
+	res, err := c.fetch(rawURL, referer)
+	if err != nil {
+		return "", err
+	}
+	return res.body, nil
+}
+
+// fetch is fetchText keeping the response headers
+func (c *httpClient) fetch(rawURL string, referer string) (*fetchResult, error) {
 	var lastErr error
+	var firstStatus *statusError
+	differ := false
 
 	for attempt := 0; attempt <= c.retry; attempt++ {
-		body, err := c.fetchOnce(rawURL, referer)
+		res, err := c.fetchOnce(rawURL, referer)
 		if err == nil {
-			return body, nil
+			return res, nil
 		}
 		lastErr = err
 		c.log.Debugf("fetch %s failed (attempt %d/%d): %v", rawURL, attempt+1, c.retry+1, err)
+
+		var se *statusError
+		if errors.As(err, &se) {
+			if firstStatus == nil {
+				firstStatus = se
+			} else if se.res.body != firstStatus.res.body {
+				differ = true
+			}
+			se.retriesDiffer = differ
+		}
 	}
 
-	return "", lastErr
+	return nil, lastErr
 }
 
-func (c *httpClient) fetchOnce(rawURL string, referer string) (string, error) {
+func (c *httpClient) fetchOnce(rawURL string, referer string) (*fetchResult, error) {
 	parsed, err := url.Parse(rawURL)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	req.Header.Set("User-Agent", userAgent)
@@ -93,25 +138,31 @@ func (c *httpClient) fetchOnce(rawURL string, referer string) (string, error) {
 		req.Header.Set("Referer", referer)
 	}
 
+	if c.limiter != nil {
+		release := c.limiter.acquire(rawURL)
+		defer release()
+	}
+
 	c.log.Debugf("GET %s", rawURL)
 
 	resp, err := c.client.Do(req)
 	if err != nil {
 		// TLS errors or other network errors should be skipped silently,
 		// so just return the error to be logged in debug mode only.
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, &statusError{res: &fetchResult{url: resp.Request.URL.String(), status: resp.StatusCode, body: string(data), header: resp.Header}}
 	}
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	c.log.Debugf("Fetched %s (%d bytes)", parsed.Host, len(data))
-	return string(data), nil
+	return &fetchResult{url: resp.Request.URL.String(), status: resp.StatusCode, body: string(data), header: resp.Header}, nil
 }
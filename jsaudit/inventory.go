@@ -0,0 +1,169 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Origin classes reported in scriptEntry.Origin
+const (
+	originFirstParty = "first-party" // same host as the page
+	originSameSite   = "same-site"   // same registrable domain (eTLD+1)
+	originThirdParty = "third-party"
+)
+
+// categoryUnknown is the category of third parties not in vendors.json,
+// whose categories are cdn, analytics, advertising, tag-manager and chat
+const categoryUnknown = "unknown"
+
+//go:embed vendors.json
+var embeddedVendors []byte
+
+// vendor is a third party known to serve scripts from its domains
+type vendor struct {
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Domains  []string `json:"domains"`
+}
+
+// vendorSummary groups the third-party scripts of one vendor, or of one
+// registrable domain for vendors not in the database
+type vendorSummary struct {
+	Vendor     string   `json:"vendor"`
+	Category   string   `json:"category"`
+	Domains    []string `json:"domains"`
+	Scripts    int      `json:"scripts"`
+	Pages      int      `json:"pages"`
+	Vulnerable bool     `json:"vulnerable"`
+}
+
+// loadVendors parses the embedded vendor database
+func loadVendors() ([]vendor, error) {
+	var vendors []vendor
+	if err := json.Unmarshal(embeddedVendors, &vendors); err != nil {
+		return nil, err
+	}
+	return vendors, nil
+}
+
+// registrableDomain returns the eTLD+1 of a host, like isapex, or the host
+// itself for IP addresses, localhost and public suffixes
+func registrableDomain(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	etld1, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return etld1
+}
+
+// classifyOrigin places a script host relative to the page host
+func classifyOrigin(pageHost, scriptHost string) string {
+	pageHost = strings.ToLower(pageHost)
+	scriptHost = strings.ToLower(scriptHost)
+	switch {
+	case pageHost == scriptHost:
+		return originFirstParty
+	case registrableDomain(pageHost) == registrableDomain(scriptHost):
+		return originSameSite
+	}
+	return originThirdParty
+}
+
+// matchVendor returns the vendor owning host, the one with the longest
+// matching domain winning ("assets.adobedtm.com" over "adobedtm.com")
+func matchVendor(vendors []vendor, host string) (vendor, bool) {
+	host = strings.ToLower(host)
+	var best vendor
+	bestLen := 0
+	for _, v := range vendors {
+		for _, d := range v.Domains {
+			if (host == d || strings.HasSuffix(host, "."+d)) && len(d) > bestLen {
+				best, bestLen = v, len(d)
+			}
+		}
+	}
+	return best, bestLen > 0
+}
+
+// classifyScripts sets the origin class of every script and the vendor of
+// third-party ones, and returns the per-vendor summary
+func classifyScripts(scripts []scriptEntry, vendors []vendor) []vendorSummary {
+	type group struct {
+		summary vendorSummary
+		domains map[string]struct{}
+		pages   map[string]struct{}
+	}
+	groups := make(map[string]*group)
+
+	for i := range scripts {
+		entry := &scripts[i]
+		if entry.Source == sourceInline || len(entry.Pages) == 0 {
+			entry.Origin = originFirstParty
+			continue
+		}
+		page, err := url.Parse(entry.Pages[0])
+		if err != nil {
+			continue
+		}
+		u, err := url.Parse(entry.URL)
+		if err != nil {
+			continue
+		}
+
+		entry.Origin = classifyOrigin(page.Hostname(), u.Hostname())
+		if entry.Origin != originThirdParty {
+			continue
+		}
+
+		domain := registrableDomain(u.Hostname())
+		name, category := domain, categoryUnknown
+		if v, ok := matchVendor(vendors, u.Hostname()); ok {
+			name, category = v.Name, v.Category
+			entry.Vendor = v.Name
+		}
+		entry.Category = category
+
+		g, ok := groups[name]
+		if !ok {
+			g = &group{
+				summary: vendorSummary{Vendor: name, Category: category},
+				domains: make(map[string]struct{}),
+				pages:   make(map[string]struct{}),
+			}
+			groups[name] = g
+		}
+		g.summary.Scripts++
+		g.domains[strings.ToLower(u.Hostname())] = struct{}{}
+		for _, p := range entry.Pages {
+			g.pages[p] = struct{}{}
+		}
+		for _, lib := range entry.Libraries {
+			if lib.Vulnerable {
+				g.summary.Vulnerable = true
+			}
+		}
+	}
+
+	summaries := make([]vendorSummary, 0, len(groups))
+	for _, g := range groups {
+		for d := range g.domains {
+			g.summary.Domains = append(g.summary.Domains, d)
+		}
+		sort.Strings(g.summary.Domains)
+		g.summary.Pages = len(g.pages)
+		summaries = append(summaries, g.summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Category != summaries[j].Category {
+			return summaries[i].Category < summaries[j].Category
+		}
+		return summaries[i].Vendor < summaries[j].Vendor
+	})
+	return summaries
+}
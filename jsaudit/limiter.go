@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostLimiter bounds the number of in-flight requests to each host and
+// spaces out the start of consecutive requests to the same host.
+type hostLimiter struct {
+	perHost int
+	delay   time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostSlot
+}
+
+type hostSlot struct {
+	sem  chan struct{}
+	next time.Time // earliest start of the next request
+}
+
+func newHostLimiter(perHost int, delay time.Duration) *hostLimiter {
+	if perHost < 1 {
+		perHost = 1
+	}
+	return &hostLimiter{
+		perHost: perHost,
+		delay:   delay,
+		hosts:   make(map[string]*hostSlot),
+	}
+}
+
+// acquire blocks until a request to the host of rawURL may start and returns
+// the function releasing the slot once the request is done.
+func (l *hostLimiter) acquire(rawURL string) func() {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		host = strings.ToLower(u.Host)
+	}
+
+	l.mu.Lock()
+	slot, ok := l.hosts[host]
+	if !ok {
+		slot = &hostSlot{sem: make(chan struct{}, l.perHost)}
+		l.hosts[host] = slot
+	}
+	l.mu.Unlock()
+
+	slot.sem <- struct{}{}
+
+	if l.delay > 0 {
+		l.mu.Lock()
+		now := time.Now()
+		start := slot.next
+		if start.Before(now) {
+			start = now
+		}
+		slot.next = start.Add(l.delay)
+		l.mu.Unlock()
+
+		time.Sleep(time.Until(start))
+	}
+
+	return func() { <-slot.sem }
+}
@@ -10,31 +10,68 @@ import (
 )
 
 type cliOptions struct {
-	targetURL string
-	timeout   time.Duration
-	retry     int
-	delay     time.Duration
-	debug     bool
-	refreshDB bool
+	targetURL         string
+	targetsFile       string
+	targetConcurrency int
+	timeout           time.Duration
+	retry             int
+	delay             time.Duration
+	concurrency       int
+	perHost           int
+	chunkDepth        int
+	maxChunks         int
+	crawlDepth        int
+	maxPages          int
+	debug             bool
+	refreshDB         bool
+	dbPath            string
+	maxDBAge          time.Duration
+	failStaleDB       bool
+	format            string
 }
 
 func parseCLI() cliOptions {
 	fs := pflag.NewFlagSet("jsaudit", pflag.ExitOnError)
 
+	targetsFile := fs.String("targets", "", "file with one target URL per line ('-' for stdin), reports written as NDJSON")
+	targetConcurrency := fs.Int("target-concurrency", 4, "number of targets scanned in parallel with --targets")
 	timeoutSec := fs.Int("timeout", 2, "HTTP timeout in seconds")
 	retry := fs.Int("retry", 2, "number of retries on network errors/timeouts")
-	delayMs := fs.Int("delay", 0, "delay in milliseconds between script requests")
+	delayMs := fs.Int("delay", 0, "delay in milliseconds between requests to the same host")
+	concurrency := fs.Int("concurrency", 8, "number of scripts fetched in parallel")
+	perHost := fs.Int("per-host", 4, "maximum parallel requests to a single host")
+	chunkDepth := fs.Int("chunk-depth", 2, "levels of dynamically imported chunks to follow (0 disables)")
+	maxChunks := fs.Int("max-chunks", 100, "maximum number of chunks fetched")
+	crawlDepth := fs.Int("crawl-depth", 0, "levels of same-origin links and sitemap.xml entries to crawl (0 scans the target page only)")
+	maxPages := fs.Int("max-pages", 20, "maximum number of pages crawled")
 	debug := fs.Bool("debug", false, "enable debug logging to stderr")
 	refreshDB := fs.Bool("refresh-db", false, "refresh local jsaudit-db.json from RetireJS and exit")
+	dbPath := fs.String("db", "", "path to a RetireJS DB (default: refreshed copy, then embedded)")
+	maxDBAgeDays := fs.Int("max-db-age", 30, "warn when the DB is older than this many days (0 disables)")
+	format := fs.String("format", "json", "output format: json or sarif")
+	failStaleDB := fs.Bool("fail-on-stale-db", false, "exit with an error instead of warning when the DB is too old")
 
 	fs.Usage = func() {
-		fmt.Fprintf(fs.Output(), "Usage: jsaudit <url> [options]\n\n")
+		fmt.Fprintf(fs.Output(), "Usage: jsaudit <url> [options]\n")
+		fmt.Fprintf(fs.Output(), "       jsaudit --targets=<file|-> [options]\n\n")
 		fmt.Fprintln(fs.Output(), "Options:")
+		fmt.Fprintln(fs.Output(), "  --targets=<file>  scan one URL per line ('-' for stdin), one NDJSON report per target")
+		fmt.Fprintln(fs.Output(), "  --target-concurrency=<n>  targets scanned in parallel (default: 4)")
 		fmt.Fprintln(fs.Output(), "  --timeout=<s>     HTTP timeout in seconds (default: 2)")
 		fmt.Fprintln(fs.Output(), "  --retry=<n>       retries on network errors/timeouts (default: 2)")
-		fmt.Fprintln(fs.Output(), "  --delay=<ms>      delay in milliseconds between script requests (default: 0)")
+		fmt.Fprintln(fs.Output(), "  --delay=<ms>      delay in milliseconds between requests to the same host (default: 0)")
+		fmt.Fprintln(fs.Output(), "  --concurrency=<n> scripts fetched in parallel (default: 8)")
+		fmt.Fprintln(fs.Output(), "  --per-host=<n>    parallel requests to a single host (default: 4)")
+		fmt.Fprintln(fs.Output(), "  --chunk-depth=<n> levels of dynamically imported chunks to follow (default: 2)")
+		fmt.Fprintln(fs.Output(), "  --max-chunks=<n>  maximum number of chunks fetched (default: 100)")
+		fmt.Fprintln(fs.Output(), "  --crawl-depth=<n> levels of same-origin links and sitemap.xml to crawl (default: 0)")
+		fmt.Fprintln(fs.Output(), "  --max-pages=<n>   maximum number of pages crawled (default: 20)")
+		fmt.Fprintln(fs.Output(), "  --format=<f>      output format: json or sarif (default: json)")
 		fmt.Fprintln(fs.Output(), "  --debug           enable debug logging to stderr")
 		fmt.Fprintln(fs.Output(), "  --refresh-db      refresh local jsaudit-db.json from RetireJS and exit")
+		fmt.Fprintln(fs.Output(), "  --db=<path>       RetireJS DB to use (default: refreshed copy, then embedded)")
+		fmt.Fprintln(fs.Output(), "  --max-db-age=<d>  warn when the DB is older than this many days (default: 30)")
+		fmt.Fprintln(fs.Output(), "  --fail-on-stale-db  fail instead of warning when the DB is too old")
 	}
 
 	// Parse flags first
@@ -49,18 +86,36 @@ func parseCLI() cliOptions {
 		target = args[0]
 	}
 
-	if target == "" && !*refreshDB {
+	if target == "" && *targetsFile == "" && !*refreshDB {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *format != "json" && *format != "sarif" {
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
 		fs.Usage()
 		os.Exit(1)
 	}
 
 	return cliOptions{
-		targetURL: target,
-		timeout:   time.Duration(*timeoutSec) * time.Second,
-		retry:     *retry,
-		delay:     time.Duration(*delayMs) * time.Millisecond,
-		debug:     *debug,
-		refreshDB: *refreshDB,
+		targetURL:         target,
+		targetsFile:       *targetsFile,
+		targetConcurrency: *targetConcurrency,
+		timeout:           time.Duration(*timeoutSec) * time.Second,
+		retry:             *retry,
+		delay:             time.Duration(*delayMs) * time.Millisecond,
+		concurrency:       *concurrency,
+		perHost:           *perHost,
+		chunkDepth:        *chunkDepth,
+		maxChunks:         *maxChunks,
+		crawlDepth:        *crawlDepth,
+		maxPages:          *maxPages,
+		debug:             *debug,
+		refreshDB:         *refreshDB,
+		dbPath:            *dbPath,
+		maxDBAge:          time.Duration(*maxDBAgeDays) * 24 * time.Hour,
+		failStaleDB:       *failStaleDB,
+		format:            *format,
 	}
 }
 
@@ -77,11 +132,34 @@ func main() {
 		return
 	}
 
-	db, err := loadDB(logger)
+	db, dbInfo, err := loadDB(opts.dbPath, logger)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to load vulnerability DB: %v\n", err)
 		os.Exit(1)
 	}
+	if age, ok := dbInfo.age(); ok && opts.maxDBAge > 0 && age > opts.maxDBAge {
+		msg := fmt.Sprintf("vulnerability DB is %.0f days old (max %.0f); run --refresh-db", age.Hours()/24, opts.maxDBAge.Hours()/24)
+		if opts.failStaleDB {
+			fmt.Fprintf(os.Stderr, "error: %s\n", msg)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "warning: %s\n", msg)
+	}
+
+	if opts.targetsFile != "" {
+		targets, err := readTargets(opts.targetsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read targets: %v\n", err)
+			os.Exit(1)
+		}
+		failed := runBatch(targets, opts, db, dbInfo, os.Stdout, logger)
+		logger.Debugf("Scanned %d targets, %d failed", len(targets), failed)
+		if failed > 0 {
+			fmt.Fprintf(os.Stderr, "%d of %d targets failed\n", failed, len(targets))
+			os.Exit(1)
+		}
+		return
+	}
 
 	client := newHTTPClient(opts.timeout, logger)
 
@@ -90,12 +168,17 @@ func main() {
 		fmt.Fprintf(os.Stderr, "scan error: %v\n", err)
 		os.Exit(1)
 	}
+	report.DB = dbInfo
+
+	var out any = report
+	if opts.format == "sarif" {
+		out = toSARIF(report)
+	}
 
 	enc := json.NewEncoder(os.Stdout)
 	enc.SetIndent("", "  ")
-	if err := enc.Encode(report); err != nil {
+	if err := enc.Encode(out); err != nil {
 		fmt.Fprintf(os.Stderr, "failed to encode JSON: %v\n", err)
 		os.Exit(1)
 	}
 }
-
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// scriptAttrs are the loading attributes of a script tag or preload link
+type scriptAttrs struct {
+	Tag         string `json:"tag"`
+	Type        string `json:"type,omitempty"`
+	Module      bool   `json:"module,omitempty"`
+	NoModule    bool   `json:"nomodule,omitempty"`
+	Async       bool   `json:"async,omitempty"`
+	Defer       bool   `json:"defer,omitempty"`
+	Integrity   string `json:"integrity,omitempty"`
+	CrossOrigin string `json:"crossorigin,omitempty"`
+
+	nonce string // kept out of the report, only checked against the CSP
+}
+
+type externalScript struct {
+	url   string
+	attrs scriptAttrs
+}
+
+type inlineScript struct {
+	offset int
+	body   string
+	attrs  scriptAttrs
+}
+
+// page is what jsaudit needs from an HTML document
+type page struct {
+	base     *url.URL
+	external []externalScript
+	inline   []inlineScript
+	links    []string // absolute <a>/<area> targets without fragment
+	csp      []string // <meta http-equiv="Content-Security-Policy"> policies
+}
+
+type rawExternal struct {
+	ref   string
+	attrs scriptAttrs
+}
+
+// parsePage tokenizes an HTML document and collects its scripts. External
+// URLs are resolved against the first <base href>, which applies to the
+// whole document, so they are only resolved once the document is read.
+// Comments and the content of <noscript> are not markup for the tokenizer.
+//
+// Both type=module and nomodule scripts are kept: the first runs in modern
+// browsers, the second still ships to legacy ones.
+func parsePage(doc string, pageURL string) (*page, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &page{base: base}
+	var refs []rawExternal
+	var hrefs []string
+	baseSet := false
+
+	z := html.NewTokenizer(strings.NewReader(doc))
+	offset := 0
+	var pendingInline *scriptAttrs
+
+	for {
+		tt := z.Next()
+		raw := z.Raw()
+		start := offset
+		offset += len(raw)
+
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				p.external = resolveExternal(refs, p.base)
+				p.links = resolveLinks(hrefs, p.base)
+				return p, nil
+			}
+			return nil, z.Err()
+
+		case html.TextToken:
+			if pendingInline != nil && strings.TrimSpace(string(raw)) != "" {
+				p.inline = append(p.inline, inlineScript{
+					offset: start,
+					body:   string(raw),
+					attrs:  *pendingInline,
+				})
+			}
+
+		case html.EndTagToken:
+			pendingInline = nil
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			attrs := make(map[string]string)
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				k := string(key)
+				if _, dup := attrs[k]; !dup { // the first occurrence wins
+					attrs[k] = string(val)
+				}
+			}
+
+			switch string(name) {
+			case "base":
+				if href, ok := attrs["href"]; ok && !baseSet {
+					baseSet = true
+					if u, err := url.Parse(strings.TrimSpace(href)); err == nil {
+						p.base = base.ResolveReference(u)
+					}
+				}
+
+			case "script":
+				sa := newScriptAttrs("script", attrs)
+				if !isJavaScriptType(sa.Type) {
+					continue
+				}
+				if src, ok := attrs["src"]; ok {
+					refs = append(refs, rawExternal{ref: strings.TrimSpace(src), attrs: sa})
+				} else if tt == html.StartTagToken {
+					pendingInline = &sa
+				}
+
+			case "meta":
+				if strings.EqualFold(strings.TrimSpace(attrs["http-equiv"]), "Content-Security-Policy") {
+					p.csp = append(p.csp, attrs["content"])
+				}
+
+			case "a", "area":
+				if href := strings.TrimSpace(attrs["href"]); href != "" {
+					hrefs = append(hrefs, href)
+				}
+
+			case "link":
+				rel := strings.ToLower(attrs["rel"])
+				as := strings.ToLower(strings.TrimSpace(attrs["as"]))
+				href := strings.TrimSpace(attrs["href"])
+				if href == "" {
+					continue
+				}
+				// Include JS files hinted via module/preload links.
+				if !(hasRelToken(rel, "modulepreload") || (hasRelToken(rel, "preload") && as == "script")) {
+					continue
+				}
+				sa := newScriptAttrs("link", attrs)
+				sa.Module = hasRelToken(rel, "modulepreload")
+				refs = append(refs, rawExternal{ref: href, attrs: sa})
+			}
+		}
+	}
+}
+
+func newScriptAttrs(tag string, attrs map[string]string) scriptAttrs {
+	sa := scriptAttrs{
+		Tag:         tag,
+		Type:        strings.ToLower(strings.TrimSpace(attrs["type"])),
+		Integrity:   strings.TrimSpace(attrs["integrity"]),
+		CrossOrigin: strings.ToLower(strings.TrimSpace(attrs["crossorigin"])),
+		nonce:       attrs["nonce"],
+	}
+	_, sa.NoModule = attrs["nomodule"]
+	_, sa.Async = attrs["async"]
+	_, sa.Defer = attrs["defer"]
+	sa.Module = sa.Type == "module"
+	if _, ok := attrs["crossorigin"]; ok && sa.CrossOrigin == "" {
+		sa.CrossOrigin = "anonymous" // a bare attribute means anonymous
+	}
+	return sa
+}
+
+// resolveExternal resolves script references against the document base,
+// keeping the first occurrence of every URL
+func resolveExternal(refs []rawExternal, base *url.URL) []externalScript {
+	seen := make(map[string]struct{})
+	var scripts []externalScript
+	for _, ref := range refs {
+		var urls []string
+		addResolvedURL(ref.ref, base, seen, &urls)
+		if len(urls) == 1 {
+			scripts = append(scripts, externalScript{url: urls[0], attrs: ref.attrs})
+		}
+	}
+	return scripts
+}
+
+// resolveLinks resolves link targets against the document base, dropping
+// fragments and keeping only http(s) URLs, each once
+func resolveLinks(hrefs []string, base *url.URL) []string {
+	seen := make(map[string]struct{})
+	var links []string
+	for _, href := range hrefs {
+		u, err := url.Parse(href)
+		if err != nil {
+			continue
+		}
+		u = base.ResolveReference(u)
+		if u.Scheme != "http" && u.Scheme != "https" {
+			continue
+		}
+		u.Fragment = ""
+		u.RawFragment = ""
+		if _, ok := seen[u.String()]; ok {
+			continue
+		}
+		seen[u.String()] = struct{}{}
+		links = append(links, u.String())
+	}
+	return links
+}
+
+// isJavaScriptType reports whether a <script type> holds code browsers run.
+// text/babel is left out: it only runs when a Babel transpiler is loaded.
+func isJavaScriptType(typ string) bool {
+	if i := strings.Index(typ, ";"); i >= 0 {
+		typ = strings.TrimSpace(typ[:i])
+	}
+
+	switch typ {
+	case "", "module", "text/javascript", "application/javascript", "application/x-javascript",
+		"text/ecmascript", "application/ecmascript", "text/jscript":
+		return true
+	}
+	return false
+}
+
+func hasRelToken(rel, token string) bool {
+	for _, part := range strings.Fields(strings.ToLower(rel)) {
+		if part == token {
+			return true
+		}
+	}
+	return false
+}
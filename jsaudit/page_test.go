@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePage(t *testing.T) {
+	const doc = `<!DOCTYPE html>
+<html><head>
+<script src="before-base.js"></script>
+<base href="https://cdn.ex.com/assets/">
+<base href="/ignored/">
+<meta http-equiv="content-security-policy" content="script-src 'self'">
+<link rel="modulepreload" href="vendor.mjs">
+<link rel="preload" as="script" href="preloaded.js">
+<link rel="preload" as="style" href="style.css">
+<script type="module" src="app.mjs"></script>
+<script nomodule src="legacy.js" integrity="sha384-abc" crossorigin></script>
+<script type="text/javascript; charset=utf-8" src="typed.js" async></script>
+<script type="text/babel" src="jsx.js"></script>
+<script type="application/ld+json">{"@type": "Organization"}</script>
+<script type="text/template"><div><script src="templated.js"></script></div></script>
+<script type="text/babel">const App = () => <div/>;</script>
+<script src="app.mjs"></script>
+<!-- <script src="commented.js"></script> -->
+<noscript><script src="noscript.js"></script></noscript>
+</head><body>
+<script nonce="r4nd">window.dataLayer = [];</script>
+<a href="../about#team">About</a><a href="mailto:a@ex.com">Mail</a><a href="https://ex.com/about">Again</a>
+</body></html>`
+
+	p, err := parsePage(doc, "https://ex.com/shop/index.html")
+	if err != nil {
+		t.Fatalf("parsePage: %v", err)
+	}
+
+	// The first <base> applies to every script, including those before it
+	wantExternal := []string{
+		"https://cdn.ex.com/assets/before-base.js",
+		"https://cdn.ex.com/assets/vendor.mjs",
+		"https://cdn.ex.com/assets/preloaded.js",
+		"https://cdn.ex.com/assets/app.mjs",
+		"https://cdn.ex.com/assets/legacy.js",
+		"https://cdn.ex.com/assets/typed.js",
+	}
+	var gotExternal []string
+	for _, s := range p.external {
+		gotExternal = append(gotExternal, s.url)
+	}
+	if !reflect.DeepEqual(gotExternal, wantExternal) {
+		t.Errorf("external = %q, want %q", gotExternal, wantExternal)
+	}
+	if len(p.external) == len(wantExternal) {
+		if a := p.external[1].attrs; a.Tag != "link" || !a.Module {
+			t.Errorf("modulepreload attrs = %+v, want a module link", a)
+		}
+		if a := p.external[4].attrs; !a.NoModule || a.Integrity != "sha384-abc" || a.CrossOrigin != "anonymous" {
+			t.Errorf("nomodule attrs = %+v", a)
+		}
+		if a := p.external[5].attrs; !a.Async || a.Type != "text/javascript; charset=utf-8" {
+			t.Errorf("typed attrs = %+v", a)
+		}
+	}
+
+	if len(p.inline) != 1 || strings.TrimSpace(p.inline[0].body) != "window.dataLayer = [];" || p.inline[0].attrs.nonce != "r4nd" {
+		t.Errorf("inline = %+v, want only the dataLayer script", p.inline)
+	}
+	if want := []string{"script-src 'self'"}; !reflect.DeepEqual(p.csp, want) {
+		t.Errorf("csp = %q, want %q", p.csp, want)
+	}
+	if want := []string{"https://cdn.ex.com/about", "https://ex.com/about"}; !reflect.DeepEqual(p.links, want) {
+		t.Errorf("links = %q, want %q", p.links, want)
+	}
+}
+
+func TestIsJavaScriptType(t *testing.T) {
+	tests := []struct {
+		typ  string
+		want bool
+	}{
+		{"", true},
+		{"module", true},
+		{"text/javascript", true},
+		{"application/javascript", true},
+		{"text/javascript; charset=utf-8", true},
+		{"text/babel", false},
+		{"text/jsx", false},
+		{"application/ld+json", false},
+		{"application/json", false},
+		{"text/template", false},
+		{"text/x-handlebars-template", false},
+		{"importmap", false},
+		{"speculationrules", false},
+	}
+
+	for _, tt := range tests {
+		if got := isJavaScriptType(tt.typ); got != tt.want {
+			t.Errorf("isJavaScriptType(%q) = %v, want %v", tt.typ, got, tt.want)
+		}
+	}
+}
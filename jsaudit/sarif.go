@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SARIF 2.1.0, limited to what code-scanning consumers read
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string         `json:"id"`
+	Name             string         `json:"name,omitempty"`
+	ShortDescription sarifText      `json:"shortDescription"`
+	FullDescription  *sarifText     `json:"fullDescription,omitempty"`
+	HelpURI          string         `json:"helpUri,omitempty"`
+	Help             *sarifText     `json:"help,omitempty"`
+	DefaultConfig    sarifRuleLevel `json:"defaultConfiguration"`
+	Properties       sarifRuleProps `json:"properties"`
+}
+
+type sarifRuleLevel struct {
+	Level string `json:"level"`
+}
+
+type sarifRuleProps struct {
+	Tags []string `json:"tags,omitempty"`
+	// security-severity is the CVSS-like score GitHub ranks alerts with
+	SecuritySeverity string `json:"security-severity,omitempty"`
+}
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifText       `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifLevel maps advisory severities to SARIF result levels
+func sarifLevel(severity string) string {
+	switch strings.ToUpper(severity) {
+	case "CRITICAL", "HIGH":
+		return "error"
+	case "MEDIUM":
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// sarifSecuritySeverity maps advisory severities to the score GitHub code
+// scanning uses to bucket alerts into critical/high/medium/low
+func sarifSecuritySeverity(severity string) string {
+	switch strings.ToUpper(severity) {
+	case "CRITICAL":
+		return "9.5"
+	case "HIGH":
+		return "8.0"
+	case "MEDIUM":
+		return "5.5"
+	default:
+		return "3.0"
+	}
+}
+
+// toSARIF converts a report into a SARIF log with one result per
+// library/advisory pair and per site finding. Rules are deduplicated by
+// advisory ID or finding type.
+func toSARIF(r *report) *sarifLog {
+	driver := sarifDriver{
+		Name:           r.Scanner,
+		Version:        r.Version,
+		InformationURI: "https://github.com/ducksify/panop-tools",
+		Rules:          []sarifRule{},
+	}
+	ruleIndex := make(map[string]int)
+	results := []sarifResult{}
+
+	addRule := func(rule sarifRule) int {
+		if i, ok := ruleIndex[rule.ID]; ok {
+			return i
+		}
+		ruleIndex[rule.ID] = len(driver.Rules)
+		driver.Rules = append(driver.Rules, rule)
+		return len(driver.Rules) - 1
+	}
+
+	for _, v := range r.Vulnerabilities {
+		rule := sarifRule{
+			ID:               v.ID,
+			Name:             v.Library,
+			ShortDescription: sarifText{Text: fmt.Sprintf("Vulnerable %s (%s)", v.Library, v.ID)},
+			DefaultConfig:    sarifRuleLevel{Level: sarifLevel(v.Severity)},
+			Properties: sarifRuleProps{
+				Tags:             append([]string{"security", "vulnerable-dependency"}, sarifCWETags(v.CWE)...),
+				SecuritySeverity: sarifSecuritySeverity(v.Severity),
+			},
+		}
+		if v.Description != "" {
+			rule.FullDescription = &sarifText{Text: v.Description}
+		}
+		if len(v.References) > 0 {
+			rule.HelpURI = v.References[0]
+			rule.Help = &sarifText{Text: strings.Join(v.References, "\n")}
+		}
+
+		msg := fmt.Sprintf("%s %s is affected by %s", v.Library, v.Version, v.ID)
+		if v.Description != "" {
+			msg += ": " + v.Description
+		}
+		if strings.HasPrefix(v.File, "inline#") {
+			msg += fmt.Sprintf(" (%s)", v.File)
+		}
+
+		results = append(results, sarifResult{
+			RuleID:    v.ID,
+			RuleIndex: addRule(rule),
+			Level:     sarifLevel(v.Severity),
+			Message:   sarifText{Text: msg},
+			Locations: sarifLocations(v.URL),
+		})
+	}
+
+	for _, f := range r.Findings {
+		id := "jsaudit/" + f.Type
+		idx := addRule(sarifRule{
+			ID:               id,
+			Name:             f.Type,
+			ShortDescription: sarifText{Text: f.Type},
+			DefaultConfig:    sarifRuleLevel{Level: sarifLevel(f.Severity)},
+			Properties: sarifRuleProps{
+				Tags:             []string{"security"},
+				SecuritySeverity: sarifSecuritySeverity(f.Severity),
+			},
+		})
+		results = append(results, sarifResult{
+			RuleID:    id,
+			RuleIndex: idx,
+			Level:     sarifLevel(f.Severity),
+			Message:   sarifText{Text: f.Description},
+			Locations: sarifLocations(f.URL),
+		})
+	}
+
+	return &sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+}
+
+func sarifLocations(uri string) []sarifLocation {
+	return []sarifLocation{{
+		PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: uri},
+		},
+	}}
+}
+
+// sarifCWETags formats CWEs the way GitHub code scanning recognizes them
+func sarifCWETags(cwes []string) []string {
+	var tags []string
+	for _, cwe := range cwes {
+		n := strings.TrimPrefix(strings.ToUpper(cwe), "CWE-")
+		tags = append(tags, "external/cwe/cwe-"+n)
+	}
+	return tags
+}
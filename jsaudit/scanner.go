@@ -1,50 +1,109 @@
 package main
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/url"
+	"path"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
 type scriptLibrary struct {
-	Library    string `json:"library"`
-	Version    string `json:"version"`
-	Vulnerable bool   `json:"vulnerable"`
-	CVEs       []struct {
-		CVE         string `json:"cve"`
-		Severity    string `json:"severity"`
-		Description string `json:"description"`
-	} `json:"cves"`
+	Library    string     `json:"library"`
+	Version    string     `json:"version"`
+	Vulnerable bool       `json:"vulnerable"`
+	DetectedBy string     `json:"detected_by"`
+	CVEs       []advisory `json:"cves"`
 }
 
+// advisory is a vulnerability affecting a detected library version. ID is
+// the CVE when there is one, otherwise the best identifier RetireJS has.
+type advisory struct {
+	ID          string   `json:"id"`
+	CVE         string   `json:"cve,omitempty"`
+	Severity    string   `json:"severity"`
+	Description string   `json:"description"`
+	CWE         []string `json:"cwe,omitempty"`
+	References  []string `json:"references,omitempty"`
+}
+
+// Script source types reported in scriptEntry.Source
+const (
+	sourceExternal = "external"
+	sourceInline   = "inline"
+	sourceChunk    = "chunk"
+)
+
 type scriptEntry struct {
-	File      string          `json:"file"`
-	URL       string          `json:"url"`
-	Status    string          `json:"status"`
-	SizeBytes int             `json:"size_bytes"`
-	FetchMS   int64           `json:"fetch_ms"`
-	Libraries []scriptLibrary `json:"libraries"`
+	File        string          `json:"file"`
+	URL         string          `json:"url"`
+	Source      string          `json:"source"`
+	Parent      string          `json:"parent,omitempty"` // script importing a chunk
+	Attributes  *scriptAttrs    `json:"attributes,omitempty"`
+	Offset      int             `json:"offset,omitempty"` // byte offset of an inline script body in the page
+	Status      string          `json:"status"`
+	WAF         string          `json:"waf,omitempty"`          // vendor of the WAF blocking the script
+	BlockReason string          `json:"block_reason,omitempty"` // why the response is not the script
+	SizeBytes   int             `json:"size_bytes"`
+	FetchMS     int64           `json:"fetch_ms"`
+	Libraries   []scriptLibrary `json:"libraries"`
+	SourceMap   *sourceMapInfo  `json:"source_map,omitempty"`
+	SHA1        string          `json:"sha1,omitempty"`
+	SRI         string          `json:"sri,omitempty"`      // integrity attribute check
+	Aliases     []string        `json:"aliases,omitempty"`  // other URLs serving the same content
+	Pages       []string        `json:"pages"`              // pages the script was seen on
+	Origin      string          `json:"origin,omitempty"`   // first-party, same-site or third-party
+	Vendor      string          `json:"vendor,omitempty"`   // known third party serving the script
+	Category    string          `json:"category,omitempty"` // vendor category of third-party scripts
+
+	chunks []string  // chunk URLs found in the script body
+	job    scriptJob // how the script was fetched, inherited by its chunks
 }
 
 type vulnerabilityFinding struct {
-	File        string `json:"file"`
-	URL         string `json:"url"`
-	Library     string `json:"library"`
-	Version     string `json:"version"`
-	CVE         string `json:"cve"`
+	File        string   `json:"file"`
+	URL         string   `json:"url"`
+	Library     string   `json:"library"`
+	Version     string   `json:"version"`
+	ID          string   `json:"id"`
+	CVE         string   `json:"cve,omitempty"`
+	Severity    string   `json:"severity"`
+	Description string   `json:"description"`
+	CWE         []string `json:"cwe,omitempty"`
+	References  []string `json:"references,omitempty"`
+}
+
+// finding is an issue of the site itself rather than of a library version
+type finding struct {
+	Type        string `json:"type"`
 	Severity    string `json:"severity"`
+	File        string `json:"file,omitempty"`
+	URL         string `json:"url"`
 	Description string `json:"description"`
 }
 
 type summary struct {
-	ScriptsFound      int   `json:"scripts_found"`
-	FetchedOK         int   `json:"fetched_ok"`
-	WAFBlocked        int   `json:"waf_blocked"`
-	Failed            int   `json:"failed"`
-	Vulnerabilities   int   `json:"vulnerabilities"`
-	LibrariesDetected int   `json:"libraries_detected"`
-	ScanDurationMS    int64 `json:"scan_duration_ms"`
+	ScriptsFound      int      `json:"scripts_found"`
+	InlineScripts     int      `json:"inline_scripts"`
+	ChunksFound       int      `json:"chunks_found"`
+	FetchedOK         int      `json:"fetched_ok"`
+	WAFBlocked        int      `json:"waf_blocked"`
+	WAFVendors        []string `json:"waf_vendors,omitempty"`
+	Failed            int      `json:"failed"`
+	Vulnerabilities   int      `json:"vulnerabilities"`
+	Findings          int      `json:"findings"`
+	LibrariesDetected int      `json:"libraries_detected"`
+	PagesScanned      int      `json:"pages_scanned"`
+	FirstParty        int      `json:"first_party"`
+	SameSite          int      `json:"same_site"`
+	ThirdParty        int      `json:"third_party"`
+	ScanDurationMS    int64    `json:"scan_duration_ms"`
 }
 
 type report struct {
@@ -52,15 +111,22 @@ type report struct {
 	Version         string                 `json:"version"`
 	Target          string                 `json:"target"`
 	Date            string                 `json:"date"`
+	DB              *dbInfo                `json:"db,omitempty"`
 	Summary         summary                `json:"summary"`
+	Pages           []*crawledPage         `json:"pages,omitempty"`
+	CSP             []cspPolicy            `json:"csp,omitempty"`
+	Vendors         []vendorSummary        `json:"vendors"`
 	Scripts         []scriptEntry          `json:"scripts"`
 	Vulnerabilities []vulnerabilityFinding `json:"vulnerabilities"`
+	Findings        []finding              `json:"findings"`
 }
 
 func runScan(opts cliOptions, client *httpClient, db *db, log *logger) (*report, error) {
 	start := time.Now()
 
 	client.setRetry(opts.retry)
+	// Every request of the scan, retries and source maps included, is paced
+	client.setLimiter(newHostLimiter(opts.perHost, opts.delay))
 
 	rep := &report{
 		Scanner: "jsaudit-go",
@@ -69,90 +135,204 @@ func runScan(opts cliOptions, client *httpClient, db *db, log *logger) (*report,
 		Date:    time.Now().UTC().Format(time.RFC3339),
 	}
 
-	// Fetch main page
-	html, err := client.fetchText(opts.targetURL, "")
+	// Fetch the main page, and the rest of the site in crawl mode
+	pages, err := crawlPages(opts, client, log)
 	if err != nil {
 		return nil, err
 	}
+	for _, cp := range pages {
+		if cp.Status == "ok" {
+			rep.Summary.PagesScanned++
+		}
+		if cp.WAF != "" {
+			rep.Summary.WAFVendors = appendUnique(rep.Summary.WAFVendors, cp.WAF)
+		}
+	}
+	if opts.crawlDepth > 0 {
+		rep.Pages = pages
+	}
 
-	scriptURLs := extractScriptURLs(html, opts.targetURL)
-	rep.Summary.ScriptsFound = len(scriptURLs)
+	// Scripts are deduplicated by URL across pages, keeping the attributes
+	// and page of their first occurrence
+	var jobs []scriptJob
+	var attrs []scriptAttrs
+	var seenOn [][]string
+	jobIndex := make(map[string]int)
+	var inlineScripts []pageInline
+	inlineIndex := make(map[string]int)
+	for _, cp := range pages {
+		if cp.page == nil {
+			continue
+		}
+		pageBase := cp.page.base.String()
+		for _, ext := range cp.page.external {
+			if i, ok := jobIndex[ext.url]; ok {
+				seenOn[i] = appendUnique(seenOn[i], cp.URL)
+				continue
+			}
+			jobIndex[ext.url] = len(jobs)
+			jobs = append(jobs, scriptJob{url: ext.url, page: cp.URL, pageBase: pageBase, integrity: ext.attrs.Integrity})
+			attrs = append(attrs, ext.attrs)
+			seenOn = append(seenOn, []string{cp.URL})
+		}
+		for _, inline := range cp.page.inline {
+			sum := sha1.Sum([]byte(inline.body))
+			key := hex.EncodeToString(sum[:])
+			if i, ok := inlineIndex[key]; ok {
+				inlineScripts[i].pages = appendUnique(inlineScripts[i].pages, cp.URL)
+				continue
+			}
+			inlineIndex[key] = len(inlineScripts)
+			inlineScripts = append(inlineScripts, pageInline{
+				inlineScript: inline,
+				sha1:         key,
+				pageBase:     pageBase,
+				pages:        []string{cp.URL},
+			})
+		}
+	}
+	rep.Summary.ScriptsFound = len(jobs)
 
-	if len(scriptURLs) == 0 {
-		log.Debugf("No external <script> URLs found; only main page fetched: %s", opts.targetURL)
+	if len(jobs) == 0 {
+		log.Debugf("No external <script> URLs found; only pages fetched: %s", opts.targetURL)
 	} else {
-		log.Debugf("Discovered %d script URLs:", len(scriptURLs))
-		for i, u := range scriptURLs {
-			log.Debugf("  [%d] %s", i+1, u)
+		log.Debugf("Discovered %d script URLs:", len(jobs))
+		for i, j := range jobs {
+			log.Debugf("  [%d] %s", i+1, j.url)
 		}
 	}
 
-	var scripts []scriptEntry
-	var findings []vulnerabilityFinding
-
-	for _, scriptURL := range scriptURLs {
-		if opts.delay > 0 {
-			time.Sleep(opts.delay)
-		}
+	scripts := fetchScripts(jobs, opts, client, db, log)
+	for i := range scripts {
+		scripts[i].Attributes = &attrs[i]
+		scripts[i].Pages = seenOn[i]
+	}
 
-		entry := scriptEntry{
-			URL:    scriptURL,
-			Status: "unknown",
+	// Follow the chunks loaded at run time, one level of imports at a time.
+	// A chunk is loaded on the pages of the script importing it.
+	seen := make(map[string]struct{}, len(jobs))
+	for _, j := range jobs {
+		seen[j.url] = struct{}{}
+	}
+	level := scripts
+	for depth := 1; depth <= opts.chunkDepth; depth++ {
+		var next []scriptJob
+		var parents []string
+		var parentPages [][]string
+		queue := func(chunks []string, parent string, from scriptJob, pages []string) {
+			for _, u := range chunks {
+				if _, ok := seen[u]; ok || rep.Summary.ChunksFound >= opts.maxChunks {
+					continue
+				}
+				seen[u] = struct{}{}
+				rep.Summary.ChunksFound++
+				next = append(next, scriptJob{url: u, page: from.page, pageBase: from.pageBase})
+				parents = append(parents, parent)
+				parentPages = append(parentPages, pages)
+			}
 		}
-
-		if u, err := url.Parse(scriptURL); err == nil {
-			parts := strings.Split(u.Path, "/")
-			if len(parts) > 0 {
-				entry.File = parts[len(parts)-1]
+		if depth == 1 {
+			for i, inline := range inlineScripts {
+				from := scriptJob{page: inline.pages[0], pageBase: inline.pageBase}
+				queue(extractChunkURLs(inline.pageBase, inline.pageBase, inline.body), fmt.Sprintf("inline#%d", i+1), from, inline.pages)
 			}
 		}
-
-		t0 := time.Now()
-		body, err := client.fetchText(scriptURL, opts.targetURL)
-		elapsed := time.Since(t0)
-		entry.FetchMS = elapsed.Milliseconds()
-
-		if err != nil {
-			entry.Status = "failed"
-			rep.Summary.Failed++
-			scripts = append(scripts, entry)
-			continue
+		for _, entry := range level {
+			queue(entry.chunks, entry.URL, entry.job, entry.Pages)
+		}
+		if len(next) == 0 {
+			break
 		}
 
-		entry.SizeBytes = len(body)
-
-		if len(body) < 512 {
-			entry.Status = "blocked"
-			rep.Summary.WAFBlocked++
-			scripts = append(scripts, entry)
-			continue
+		log.Debugf("Following %d chunks at depth %d", len(next), depth)
+		level = fetchScripts(next, opts, client, db, log)
+		for i := range level {
+			level[i].Source = sourceChunk
+			level[i].Parent = parents[i]
+			level[i].Pages = parentPages[i]
 		}
+		scripts = append(scripts, level...)
+	}
 
-		entry.Status = "ok"
-		rep.Summary.FetchedOK++
+	// Every tag is audited, including those merged below
+	siteFindings := auditScriptLoading(scripts)
+	if target := pages[0]; target.page != nil {
+		rep.CSP = collectCSP(target.header, target.page.csp)
+		siteFindings = append(siteFindings, evaluateCSP(rep.CSP, target.URL, scripts, inlineScripts)...)
+	}
 
-		libs := detectLibraries(scriptURL, body, db, log)
-		entry.Libraries = libs
+	// The same file is often served under several URLs (cache busting
+	// query strings, CDN mirrors): report it once
+	scripts = mergeIdenticalScripts(scripts)
 
-		for _, lib := range libs {
-			for _, c := range lib.CVEs {
-				findings = append(findings, vulnerabilityFinding{
-					File:        entry.File,
-					URL:         entry.URL,
-					Library:     lib.Library,
-					Version:     lib.Version,
-					CVE:         c.CVE,
-					Severity:    c.Severity,
-					Description: c.Description,
-				})
+	var findings []vulnerabilityFinding
+	for _, entry := range scripts {
+		switch entry.Status {
+		case "ok":
+			rep.Summary.FetchedOK++
+		case "blocked":
+			rep.Summary.WAFBlocked++
+			if entry.WAF != "" {
+				rep.Summary.WAFVendors = appendUnique(rep.Summary.WAFVendors, entry.WAF)
 			}
+		case "failed":
+			rep.Summary.Failed++
+		}
+		findings = appendFindings(findings, entry)
+		if entry.SourceMap != nil && entry.SourceMap.Exposed {
+			siteFindings = append(siteFindings, finding{
+				Type:        "exposed-source-map",
+				Severity:    "LOW",
+				File:        entry.File,
+				URL:         entry.SourceMap.URL,
+				Description: fmt.Sprintf("Source map is publicly accessible and reveals %d original source files", entry.SourceMap.Sources),
+			})
 		}
+	}
+
+	// Inline scripts are analysed as pseudo-files named after their position
+	rep.Summary.InlineScripts = len(inlineScripts)
+	log.Debugf("Discovered %d inline scripts", len(inlineScripts))
 
+	for i, inline := range inlineScripts {
+		entry := scriptEntry{
+			File:      fmt.Sprintf("inline#%d", i+1),
+			URL:       inline.pages[0],
+			Source:    sourceInline,
+			Offset:    inline.offset,
+			Status:    "ok",
+			SizeBytes: len(inline.body),
+			SHA1:      inline.sha1,
+			Pages:     inline.pages,
+		}
+		attrs := inline.attrs
+		entry.Attributes = &attrs
+		entry.Libraries = detectLibraries("", inline.body, db, log)
+		findings = appendFindings(findings, entry)
 		scripts = append(scripts, entry)
 	}
 
+	// Who the site loads code from
+	vendors, err := loadVendors()
+	if err != nil {
+		return nil, fmt.Errorf("parse vendor database: %w", err)
+	}
+	rep.Vendors = classifyScripts(scripts, vendors)
+	for _, s := range scripts {
+		switch s.Origin {
+		case originFirstParty:
+			rep.Summary.FirstParty++
+		case originSameSite:
+			rep.Summary.SameSite++
+		case originThirdParty:
+			rep.Summary.ThirdParty++
+		}
+	}
+
 	rep.Scripts = scripts
 	rep.Vulnerabilities = findings
+	rep.Findings = siteFindings
+	rep.Summary.Findings = len(siteFindings)
 	rep.Summary.Vulnerabilities = len(findings)
 	totalLibs := 0
 	for _, s := range scripts {
@@ -164,82 +344,219 @@ func runScan(opts cliOptions, client *httpClient, db *db, log *logger) (*report,
 	return rep, nil
 }
 
-func extractScriptURLs(html string, baseURL string) []string {
-	base, err := url.Parse(baseURL)
-	if err != nil {
-		return nil
+// scriptJob is a script to fetch, with the page it was found on
+type scriptJob struct {
+	url       string
+	page      string // sent as referer
+	pageBase  string // base URL chunk paths are relative to
+	integrity string // SRI attribute of the tag loading the script
+}
+
+// pageInline is an inline script with the pages it appears on
+type pageInline struct {
+	inlineScript
+	sha1     string
+	pageBase string
+	pages    []string
+}
+
+// fetchScripts fetches scripts concurrently. Every result goes to the slot
+// of its URL so the order does not depend on which fetch finishes first.
+func fetchScripts(jobs []scriptJob, opts cliOptions, client *httpClient, db *db, log *logger) []scriptEntry {
+	scripts := make([]scriptEntry, len(jobs))
+	queue := make(chan int)
+
+	workers := opts.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
 	}
 
-	scriptTagRe := regexp.MustCompile(`(?is)<script\b[^>]*\bsrc=["']([^"']+)["'][^>]*>`)
-	linkTagRe := regexp.MustCompile(`(?is)<link\b([^>]*)>`)
-	attrRe := regexp.MustCompile(`(?is)\b([a-zA-Z_:][\w:.-]*)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+))`)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				scripts[i] = fetchScript(jobs[i], client, db, log)
+			}
+		}()
+	}
+	for i := range jobs {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
 
-	seen := make(map[string]struct{})
-	var urls []string
+	return scripts
+}
 
-	for _, m := range scriptTagRe.FindAllStringSubmatch(html, -1) {
-		if len(m) < 2 {
+// mergeIdenticalScripts folds fetched scripts with the same content into the
+// first one, which lists the other URLs as aliases
+func mergeIdenticalScripts(scripts []scriptEntry) []scriptEntry {
+	first := make(map[string]int)
+	merged := scripts[:0]
+	for _, entry := range scripts {
+		if entry.Status != "ok" || entry.SHA1 == "" {
+			merged = append(merged, entry)
 			continue
 		}
-
-		addResolvedURL(strings.TrimSpace(m[1]), base, seen, &urls)
+		i, ok := first[entry.SHA1]
+		if !ok {
+			first[entry.SHA1] = len(merged)
+			merged = append(merged, entry)
+			continue
+		}
+		merged[i].Aliases = append(merged[i].Aliases, entry.URL)
+		for _, p := range entry.Pages {
+			merged[i].Pages = appendUnique(merged[i].Pages, p)
+		}
 	}
+	return merged
+}
 
-	for _, m := range linkTagRe.FindAllStringSubmatch(html, -1) {
-		if len(m) < 2 {
-			continue
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
 		}
+	}
+	return append(list, s)
+}
 
-		attrs := m[1]
-		rel := ""
-		as := ""
-		href := ""
+// fetchScript downloads one external script and detects the libraries in it
+func fetchScript(job scriptJob, client *httpClient, db *db, log *logger) scriptEntry {
+	scriptURL := job.url
+	entry := scriptEntry{
+		URL:    scriptURL,
+		Source: sourceExternal,
+		Status: "unknown",
+		job:    job,
+	}
 
-		for _, am := range attrRe.FindAllStringSubmatch(attrs, -1) {
-			if len(am) < 5 {
-				continue
-			}
+	if u, err := url.Parse(scriptURL); err == nil {
+		parts := strings.Split(u.Path, "/")
+		if len(parts) > 0 {
+			entry.File = parts[len(parts)-1]
+		}
+	}
 
-			val := am[2]
-			if val == "" {
-				val = am[3]
-			}
-			if val == "" {
-				val = am[4]
-			}
+	t0 := time.Now()
+	res, err := client.fetch(scriptURL, job.page)
+	elapsed := time.Since(t0)
+	entry.FetchMS = elapsed.Milliseconds()
 
-			switch strings.ToLower(am[1]) {
-			case "rel":
-				rel = strings.ToLower(strings.TrimSpace(val))
-			case "as":
-				as = strings.ToLower(strings.TrimSpace(val))
-			case "href":
-				href = strings.TrimSpace(val)
+	if err != nil {
+		entry.Status = "failed"
+		var se *statusError
+		if errors.As(err, &se) {
+			entry.SizeBytes = len(se.res.body)
+			// Challenge pages embed per-request tokens, so a changing HTML
+			// error page is a challenge even when no signature matches
+			dynamic := se.retriesDiffer && sniffHTML(se.res.body, se.res.header.Get("Content-Type"))
+			if v, blocked := detectBlock(se.res, false); blocked || dynamic {
+				if dynamic {
+					v.reason = joinReason(v.reason, "error page changes between retries")
+				}
+				entry.blocked(v)
 			}
 		}
+		return entry
+	}
 
-		if href == "" {
-			continue
+	if v, blocked := detectBlock(res, false); blocked {
+		// A script is served identically every time; an unidentified block
+		// that goes away on a second request was transient
+		if v.vendor == "" && client.retry > 0 {
+			log.Debugf("Refetching %s: %s", scriptURL, v.reason)
+			again, err := client.fetch(scriptURL, job.page)
+			if err == nil {
+				if _, stillBlocked := detectBlock(again, false); !stillBlocked {
+					res, blocked = again, false
+				} else if again.body != res.body {
+					v.reason = joinReason(v.reason, "page changes between retries")
+				}
+			}
 		}
-
-		// Include JS files hinted via module/preload links.
-		if !(hasRelToken(rel, "modulepreload") || (hasRelToken(rel, "preload") && as == "script")) {
-			continue
+		if blocked {
+			entry.SizeBytes = len(res.body)
+			entry.blocked(v)
+			return entry
 		}
+	}
 
-		addResolvedURL(href, base, seen, &urls)
+	body := res.body
+	entry.SizeBytes = len(body)
+
+	entry.Status = "ok"
+	sum := sha1.Sum([]byte(body))
+	entry.SHA1 = hex.EncodeToString(sum[:])
+	if job.integrity != "" {
+		entry.SRI = verifySRI(job.integrity, body)
+	}
+	entry.Libraries = detectLibraries(scriptURL, body, db, log)
+	entry.chunks = extractChunkURLs(scriptURL, job.pageBase, body)
+
+	// Bundles lose their banners when minified, their source maps do not
+	if mapURL := findSourceMapURL(scriptURL, body, res.header); mapURL != "" {
+		info, libs := fetchSourceMap(mapURL, scriptURL, client, db, log)
+		entry.SourceMap = info
+		entry.Libraries = mergeLibraries(entry.Libraries, libs)
 	}
+	return entry
+}
+
+// blocked marks a script as withheld by a WAF or bot manager
+func (e *scriptEntry) blocked(v blockVerdict) {
+	e.Status = "blocked"
+	e.WAF = v.vendor
+	e.BlockReason = v.reason
+}
+
+func joinReason(reason, extra string) string {
+	if reason == "" {
+		return extra
+	}
+	return reason + ", " + extra
+}
 
-	return urls
+// mergeLibraries adds the libraries of extra not already detected
+func mergeLibraries(libs, extra []scriptLibrary) []scriptLibrary {
+	for _, lib := range extra {
+		known := false
+		for _, l := range libs {
+			if strings.EqualFold(l.Library, lib.Library) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			libs = append(libs, lib)
+		}
+	}
+	return libs
 }
 
-func hasRelToken(rel, token string) bool {
-	for _, part := range strings.Fields(strings.ToLower(rel)) {
-		if part == token {
-			return true
+func appendFindings(findings []vulnerabilityFinding, entry scriptEntry) []vulnerabilityFinding {
+	for _, lib := range entry.Libraries {
+		for _, c := range lib.CVEs {
+			findings = append(findings, vulnerabilityFinding{
+				File:        entry.File,
+				URL:         entry.URL,
+				Library:     lib.Library,
+				Version:     lib.Version,
+				ID:          c.ID,
+				CVE:         c.CVE,
+				Severity:    c.Severity,
+				Description: c.Description,
+				CWE:         c.CWE,
+				References:  c.References,
+			})
 		}
 	}
-	return false
+	return findings
 }
 
 func addResolvedURL(raw string, base *url.URL, seen map[string]struct{}, urls *[]string) {
@@ -263,89 +580,156 @@ func addResolvedURL(raw string, base *url.URL, seen map[string]struct{}, urls *[
 	*urls = append(*urls, final)
 }
 
+// Extractors reported in scriptLibrary.DetectedBy. The first five are the
+// RetireJS extractor names.
+const (
+	extractorHash               = "hashes"
+	extractorFilename           = "filename"
+	extractorURI                = "uri"
+	extractorFileContent        = "filecontent"
+	extractorFileContentReplace = "filecontentreplace"
+	extractorHeader             = "header"
+	extractorSourceMap          = "sourcemap"
+)
+
+type detection struct {
+	version   string
+	extractor string
+}
+
 func detectLibraries(scriptURL, content string, db *db, log *logger) []scriptLibrary {
-	found := make(map[string]string) // lib -> version
-	versionCapture := `(\d+[\d.]*)`
-
-	for lib, patterns := range db.URLPatterns {
-		for _, pat := range patterns {
-			reStr := strings.ReplaceAll(pat, "§§version§§", versionCapture)
-			re, err := regexp.Compile("(?i)" + reStr)
-			if err != nil {
+	found := make(map[string]detection) // lib -> version
+
+	// A pristine library file is identified exactly by its hash
+	sum := sha1.Sum([]byte(content))
+	if m, ok := db.Hashes[hex.EncodeToString(sum[:])]; ok {
+		log.Debugf("Hash match for %s: %s %s", scriptURL, m.Library, m.Version)
+		found[m.Library] = detection{version: m.Version, extractor: extractorHash}
+		return libraryList(db, found)
+	}
+
+	filename := ""
+	if u, err := url.Parse(scriptURL); err == nil {
+		filename = path.Base(u.Path)
+	}
+
+	matchURL := func(patterns map[string][]*regexp.Regexp, target, extractor string) {
+		if target == "" {
+			return
+		}
+		for lib, res := range patterns {
+			if _, exists := found[lib]; exists {
 				continue
 			}
-			if m := re.FindStringSubmatch(scriptURL); len(m) > 1 {
-				found[lib] = m[1]
-				break
+			for _, re := range res {
+				if m := re.FindStringSubmatch(target); len(m) > 1 {
+					found[lib] = detection{version: m[1], extractor: extractor}
+					break
+				}
 			}
 		}
 	}
+	matchURL(db.compiled.filename, filename, extractorFilename)
+	matchURL(db.compiled.uri, scriptURL, extractorURI)
 
-	for lib, patterns := range db.ContentRegex {
-		for _, pat := range patterns {
-			reStr := strings.ReplaceAll(pat, "§§version§§", versionCapture)
-			re, err := regexp.Compile("(?i)" + reStr)
-			if err != nil {
-				continue
-			}
+	for lib, res := range db.compiled.content {
+		for _, re := range res {
 			if m := re.FindStringSubmatch(content); len(m) > 1 {
 				if _, exists := found[lib]; !exists {
-					found[lib] = m[1]
+					found[lib] = detection{version: m[1], extractor: extractorFileContent}
 				}
 				break
 			}
 		}
 	}
 
+	for lib, replacements := range db.compiled.replace {
+		if _, exists := found[lib]; exists {
+			continue
+		}
+		for _, r := range replacements {
+			if idx := r.re.FindStringSubmatchIndex(content); idx != nil {
+				version := string(r.re.ExpandString(nil, r.replacement, content, idx))
+				if version != "" {
+					found[lib] = detection{version: version, extractor: extractorFileContentReplace}
+					break
+				}
+			}
+		}
+	}
+
 	// Cross-check detected versions against canonical header comment version.
 	// If the header declares a higher version, prefer it (to avoid picking up
 	// requirement strings like ">= 2.6.0" as the actual library version).
 	if headerVersion := extractHeaderVersion(content); headerVersion != "" {
-		for lib, version := range found {
-			if compareVersions(headerVersion, version) > 0 {
-				log.Debugf("Version override for %s: %s -> %s (header takes precedence)", lib, version, headerVersion)
-				found[lib] = headerVersion
+		for lib, d := range found {
+			if compareVersions(headerVersion, d.version) > 0 {
+				log.Debugf("Version override for %s: %s -> %s (header takes precedence)", lib, d.version, headerVersion)
+				found[lib] = detection{version: headerVersion, extractor: extractorHeader}
 			}
 		}
 	}
 
+	return libraryList(db, found)
+}
+
+// backrefRe matches the backreferences RetireJS regexes use to tie minified
+// identifiers together, which RE2 does not support
+var backrefRe = regexp.MustCompile(`(^|[^\\])\\([1-9])`)
+
+// compileRetireRegex compiles a RetireJS regex, approximating backreferences
+// by any identifier when the regex does not compile as is
+func compileRetireRegex(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err == nil || !backrefRe.MatchString(pattern) {
+		return re, err
+	}
+	return regexp.Compile(backrefRe.ReplaceAllString(pattern, `${1}(?:[\w$]+)`))
+}
+
+// libraryList looks up every detection, sorted by library for a stable report
+func libraryList(db *db, found map[string]detection) []scriptLibrary {
 	var libs []scriptLibrary
 
-	for lib, version := range found {
-		entry, ok := db.Libs[lib]
-		if !ok {
-			continue
+	for lib, d := range found {
+		if l, ok := lookupLibrary(db, lib, d.version); ok {
+			l.DetectedBy = d.extractor
+			libs = append(libs, l)
 		}
+	}
 
-		var cves []struct {
-			CVE         string `json:"cve"`
-			Severity    string `json:"severity"`
-			Description string `json:"description"`
-		}
-		for _, v := range entry.Vulns {
-			if !versionInRange(version, v.AtOrAbove, v.Below) {
-				continue
-			}
-			cves = append(cves, struct {
-				CVE         string `json:"cve"`
-				Severity    string `json:"severity"`
-				Description string `json:"description"`
-			}{
-				CVE:         v.CVE,
-				Severity:    strings.ToUpper(v.Severity),
-				Description: v.Info,
-			})
-		}
+	sort.Slice(libs, func(i, j int) bool { return libs[i].Library < libs[j].Library })
+	return libs
+}
 
-		libs = append(libs, scriptLibrary{
-			Library:    entry.Name,
-			Version:    version,
-			Vulnerable: len(cves) > 0,
-			CVEs:       cves,
+// lookupLibrary reports a detected library version with the CVEs affecting it
+func lookupLibrary(db *db, lib, version string) (scriptLibrary, bool) {
+	entry, ok := db.Libs[lib]
+	if !ok {
+		return scriptLibrary{}, false
+	}
+
+	var cves []advisory
+	for _, v := range entry.Vulns {
+		if !versionInRange(version, v.AtOrAbove, v.Below) {
+			continue
+		}
+		cves = append(cves, advisory{
+			ID:          v.ID,
+			CVE:         v.CVE,
+			Severity:    strings.ToUpper(v.Severity),
+			Description: v.Info,
+			CWE:         v.CWE,
+			References:  v.References,
 		})
 	}
 
-	return libs
+	return scriptLibrary{
+		Library:    entry.Name,
+		Version:    version,
+		Vulnerable: len(cves) > 0,
+		CVEs:       cves,
+	}, true
 }
 
 func versionInRange(version, atOrAbove, below string) bool {
@@ -358,50 +742,6 @@ func versionInRange(version, atOrAbove, below string) bool {
 	return true
 }
 
-func compareVersions(a, b string) int {
-	parse := func(s string) []int {
-		parts := strings.Split(s, ".")
-		out := make([]int, len(parts))
-		for i, p := range parts {
-			n := 0
-			for _, ch := range p {
-				if ch < '0' || ch > '9' {
-					break
-				}
-				n = n*10 + int(ch-'0')
-			}
-			out[i] = n
-		}
-		return out
-	}
-
-	pa := parse(a)
-	pb := parse(b)
-
-	max := len(pa)
-	if len(pb) > max {
-		max = len(pb)
-	}
-
-	for i := 0; i < max; i++ {
-		va := 0
-		vb := 0
-		if i < len(pa) {
-			va = pa[i]
-		}
-		if i < len(pb) {
-			vb = pb[i]
-		}
-		if va < vb {
-			return -1
-		}
-		if va > vb {
-			return 1
-		}
-	}
-	return 0
-}
-
 // Header version patterns mirror HEADER_VERSION_PATTERNS from the Node.js
 // implementation. These are used to extract a canonical version from header
 // comments (e.g. "//! version : 2.30.1") to avoid false positives.
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// sourceMapInfo describes the source map of a script
+type sourceMapInfo struct {
+	URL string `json:"url"`
+	// Exposed is true when the map was downloaded from the site, false for
+	// maps inlined as data: URLs
+	Exposed  bool         `json:"exposed"`
+	Status   string       `json:"status"`
+	Sources  int          `json:"sources"`
+	Packages []npmPackage `json:"packages,omitempty"`
+}
+
+// npmPackage is a package inferred from the node_modules paths of a source map
+type npmPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type sourceMap struct {
+	Sources        []string  `json:"sources"`
+	SourcesContent []*string `json:"sourcesContent"`
+	// Index maps split the sources into sections
+	Sections []struct {
+		Map *sourceMap `json:"map"`
+	} `json:"sections"`
+}
+
+var (
+	sourceMappingURLRe = regexp.MustCompile(`(?m)[#@]\s*sourceMappingURL\s*=\s*(\S+)\s*(?:\*/)?\s*$`)
+	// node_modules/<name> or node_modules/@scope/<name>
+	nodeModulesRe = regexp.MustCompile(`node_modules/((?:@[^/]+/)?[^/@]+)(?:@(\d+\.\d+[\w.+-]*))?/`)
+	// pnpm layout: node_modules/.pnpm/<name>@<version>/ with "/" in scopes replaced by "+"
+	pnpmRe           = regexp.MustCompile(`node_modules/\.pnpm/((?:@[^/+]+\+)?[^/@]+)@(\d+\.\d+[\w.+-]*?)(?:_[^/]*)?/`)
+	packageVersionRe = regexp.MustCompile(`"version"\s*:\s*"(\d+\.\d+[\w.+-]*)"`)
+)
+
+// findSourceMapURL returns the absolute URL of a script's source map, from
+// the SourceMap headers or the last sourceMappingURL comment, or "" if none
+func findSourceMapURL(scriptURL, body string, header http.Header) string {
+	ref := header.Get("SourceMap")
+	if ref == "" {
+		ref = header.Get("X-SourceMap")
+	}
+	if ref == "" {
+		matches := sourceMappingURLRe.FindAllStringSubmatch(body, -1)
+		if len(matches) == 0 {
+			return ""
+		}
+		ref = matches[len(matches)-1][1]
+	}
+
+	if strings.HasPrefix(ref, "data:") {
+		return ref
+	}
+
+	base, err := url.Parse(scriptURL)
+	if err != nil {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	return base.ResolveReference(u).String()
+}
+
+// fetchSourceMap downloads or decodes the source map of a script and infers
+// the npm packages bundled into it, together with their known libraries
+func fetchSourceMap(mapURL, referer string, client *httpClient, db *db, log *logger) (*sourceMapInfo, []scriptLibrary) {
+	info := &sourceMapInfo{URL: mapURL, Status: "ok"}
+
+	var data string
+	if strings.HasPrefix(mapURL, "data:") {
+		info.URL = "data:"
+		decoded, err := decodeDataURL(mapURL)
+		if err != nil {
+			log.Debugf("Inline source map not decoded: %v", err)
+			info.Status = "invalid"
+			return info, nil
+		}
+		data = decoded
+	} else {
+		body, err := client.fetchText(mapURL, referer)
+		if err != nil {
+			info.Status = "failed"
+			return info, nil
+		}
+		data = body
+	}
+
+	var sm sourceMap
+	if err := json.Unmarshal([]byte(data), &sm); err != nil {
+		// HTML error pages and WAF challenges are served with status 200
+		log.Debugf("Source map %s is not valid JSON: %v", mapURL, err)
+		info.Status = "invalid"
+		return info, nil
+	}
+	info.Exposed = !strings.HasPrefix(mapURL, "data:")
+
+	sources, contents := flattenSourceMap(&sm)
+	info.Sources = len(sources)
+	info.Packages = inferPackages(sources, contents, db)
+	log.Debugf("Source map %s lists %d sources and %d packages", info.URL, len(sources), len(info.Packages))
+
+	var libs []scriptLibrary
+	for _, pkg := range info.Packages {
+		if pkg.Version == "" {
+			continue
+		}
+		key := libKeyForPackage(db, pkg.Name)
+		if key == "" {
+			continue
+		}
+		if lib, ok := lookupLibrary(db, key, pkg.Version); ok {
+			lib.DetectedBy = extractorSourceMap
+			libs = append(libs, lib)
+		}
+	}
+	return info, libs
+}
+
+func decodeDataURL(raw string) (string, error) {
+	meta, payload, _ := strings.Cut(strings.TrimPrefix(raw, "data:"), ",")
+	if strings.HasSuffix(meta, ";base64") {
+		data, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	return url.PathUnescape(payload)
+}
+
+// flattenSourceMap returns the sources of a map and their embedded contents
+// ("" when absent), including those of every section of an index map
+func flattenSourceMap(sm *sourceMap) ([]string, []string) {
+	var sources, contents []string
+	for i, src := range sm.Sources {
+		content := ""
+		if i < len(sm.SourcesContent) && sm.SourcesContent[i] != nil {
+			content = *sm.SourcesContent[i]
+		}
+		sources = append(sources, src)
+		contents = append(contents, content)
+	}
+	for _, section := range sm.Sections {
+		if section.Map == nil {
+			continue
+		}
+		s, c := flattenSourceMap(section.Map)
+		sources = append(sources, s...)
+		contents = append(contents, c...)
+	}
+	return sources, contents
+}
+
+// inferPackages lists the npm packages found in source paths. Versions come
+// from pnpm/versioned paths, bundled package.json files, and finally the
+// content patterns of the matching library.
+func inferPackages(sources, contents []string, db *db) []npmPackage {
+	versions := make(map[string]string)
+	firstContent := make(map[string]string)
+
+	for i, src := range sources {
+		src = strings.ReplaceAll(src, `\`, "/")
+
+		if m := pnpmRe.FindStringSubmatch(src); m != nil {
+			name := strings.Replace(m[1], "+", "/", 1)
+			versions[name] = m[2]
+		}
+
+		matches := nodeModulesRe.FindAllStringSubmatch(src, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		// The innermost node_modules is the package owning the file
+		m := matches[len(matches)-1]
+		name := m[1]
+		if name == ".pnpm" {
+			continue
+		}
+		if _, ok := versions[name]; !ok {
+			versions[name] = ""
+		}
+		if m[2] != "" {
+			versions[name] = m[2]
+		}
+
+		content := contents[i]
+		if content == "" {
+			continue
+		}
+		if strings.HasSuffix(src, "node_modules/"+name+"/package.json") {
+			if vm := packageVersionRe.FindStringSubmatch(content); vm != nil {
+				versions[name] = vm[1]
+			}
+			continue
+		}
+		if _, ok := firstContent[name]; !ok {
+			firstContent[name] = content
+		}
+	}
+
+	packages := make([]npmPackage, 0, len(versions))
+	for name, version := range versions {
+		if version == "" {
+			if content, ok := firstContent[name]; ok {
+				version = contentVersion(db, libKeyForPackage(db, name), content)
+			}
+		}
+		packages = append(packages, npmPackage{Name: name, Version: version})
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Name < packages[j].Name })
+	return packages
+}
+
+// libKeyForPackage returns the DB key of the library published as an npm
+// package, or "" if the DB does not know it
+func libKeyForPackage(db *db, name string) string {
+	if _, ok := db.Libs[name]; ok {
+		return name
+	}
+	for key, entry := range db.Libs {
+		if strings.EqualFold(entry.Name, name) || strings.EqualFold(key, name) {
+			return key
+		}
+	}
+	return ""
+}
+
+// contentVersion applies the content patterns of one library, then the
+// header comment patterns, to a source file of that library
+func contentVersion(db *db, key, content string) string {
+	if key != "" {
+		for _, re := range db.compiled.content[key] {
+			if m := re.FindStringSubmatch(content); len(m) > 1 {
+				return m[1]
+			}
+		}
+	}
+	return extractHeaderVersion(content)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInferPackages(t *testing.T) {
+	d := testDB(t, `{
+		"exlib": {
+			"npmname": "exlib",
+			"extractors": {"filecontent": ["/\\*! exlib v(§§version§§)"]}
+		}
+	}`)
+
+	tests := []struct {
+		name     string
+		sources  []string
+		contents []string
+		want     []npmPackage
+	}{
+		{
+			"plain and scoped",
+			[]string{
+				"webpack:///./node_modules/lodash/lodash.js",
+				"webpack:///./node_modules/@babel/runtime/helpers/esm/typeof.js",
+				"webpack:///./src/index.js",
+			},
+			nil,
+			[]npmPackage{{Name: "@babel/runtime"}, {Name: "lodash"}},
+		},
+		{
+			"innermost node_modules owns the file",
+			[]string{"webpack:///./node_modules/a/node_modules/@scope/b/index.js"},
+			nil,
+			[]npmPackage{{Name: "@scope/b"}},
+		},
+		{
+			"versioned path",
+			[]string{"../node_modules/react@18.2.0/cjs/react.production.min.js"},
+			nil,
+			[]npmPackage{{Name: "react", Version: "18.2.0"}},
+		},
+		{
+			"pnpm",
+			[]string{
+				"../../node_modules/.pnpm/@vue+shared@3.4.21/node_modules/@vue/shared/dist/shared.esm-bundler.js",
+				"../../node_modules/.pnpm/react-dom@18.2.0_react@18.2.0/node_modules/react-dom/index.js",
+				"../../node_modules/.pnpm/axios@1.6.7/node_modules/axios/lib/axios.js",
+			},
+			nil,
+			[]npmPackage{{Name: "@vue/shared", Version: "3.4.21"}, {Name: "axios", Version: "1.6.7"}, {Name: "react-dom", Version: "18.2.0"}},
+		},
+		{
+			"windows separators",
+			[]string{`webpack:///C:\app\node_modules\@scope\pkg\index.js`},
+			nil,
+			[]npmPackage{{Name: "@scope/pkg"}},
+		},
+		{
+			"bundled package.json",
+			[]string{"webpack:///./node_modules/lodash/lodash.js", "webpack:///./node_modules/lodash/package.json"},
+			[]string{"", `{"name": "lodash", "version": "4.17.21"}`},
+			[]npmPackage{{Name: "lodash", Version: "4.17.21"}},
+		},
+		{
+			"content patterns of the library",
+			[]string{"webpack:///./node_modules/exlib/dist/exlib.js"},
+			[]string{"/*! exlib v1.4.2 | MIT */\nvar exlib={}"},
+			[]npmPackage{{Name: "exlib", Version: "1.4.2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		contents := tt.contents
+		if contents == nil {
+			contents = make([]string, len(tt.sources))
+		}
+		if got := inferPackages(tt.sources, contents, d); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: inferPackages = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
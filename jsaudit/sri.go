@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+)
+
+// Results of the Subresource Integrity check reported in scriptEntry.SRI
+const (
+	sriValid    = "valid"
+	sriMismatch = "mismatch"
+	sriInvalid  = "invalid" // no supported hash in the attribute
+)
+
+// sriHashes are the algorithms browsers accept, weakest first
+var sriHashes = []struct {
+	name string
+	new  func() hash.Hash
+}{
+	{"sha256", sha256.New},
+	{"sha384", sha512.New384},
+	{"sha512", sha512.New},
+}
+
+// verifySRI checks body against an integrity attribute the way browsers do:
+// only the hashes of the strongest algorithm listed count, and any of them
+// matching is enough.
+func verifySRI(integrity, body string) string {
+	expected := make(map[string][]string)
+	for _, token := range strings.Fields(integrity) {
+		token, _, _ = strings.Cut(token, "?") // options are reserved
+		alg, digest, ok := strings.Cut(token, "-")
+		if !ok {
+			continue
+		}
+		expected[strings.ToLower(alg)] = append(expected[strings.ToLower(alg)], digest)
+	}
+
+	for i := len(sriHashes) - 1; i >= 0; i-- {
+		digests, ok := expected[sriHashes[i].name]
+		if !ok {
+			continue
+		}
+		h := sriHashes[i].new()
+		h.Write([]byte(body))
+		sum := base64.StdEncoding.EncodeToString(h.Sum(nil))
+		for _, d := range digests {
+			if d == sum {
+				return sriValid
+			}
+		}
+		return sriMismatch
+	}
+	return sriInvalid
+}
+
+// auditScriptLoading reports external scripts loaded unsafely: over plain
+// HTTP from HTTPS pages, cross-origin without SRI, with an SRI hash not
+// matching the served file, or with SRI but no CORS request to check it.
+func auditScriptLoading(scripts []scriptEntry) []finding {
+	var findings []finding
+	add := func(typ, severity string, entry scriptEntry, format string, args ...any) {
+		findings = append(findings, finding{
+			Type:        typ,
+			Severity:    severity,
+			File:        entry.File,
+			URL:         entry.URL,
+			Description: fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, entry := range scripts {
+		if entry.Source == sourceInline {
+			continue
+		}
+		scriptURL, err := url.Parse(entry.URL)
+		if err != nil {
+			continue
+		}
+
+		if scriptURL.Scheme == "http" {
+			for _, p := range entry.Pages {
+				if strings.HasPrefix(p, "https:") {
+					add("mixed-content", "MEDIUM", entry, "Script is loaded over plain HTTP by the HTTPS page %s", p)
+					break
+				}
+			}
+		}
+
+		// Chunks are injected by their parent script, not by a tag of the page
+		if entry.Attributes == nil || len(entry.Pages) == 0 {
+			continue
+		}
+		pageURL, err := url.Parse(entry.Pages[0])
+		if err != nil {
+			continue
+		}
+		crossOrigin := !sameOrigin(pageURL, scriptURL)
+		integrity := entry.Attributes.Integrity
+
+		switch {
+		case integrity == "" && crossOrigin:
+			add("missing-sri", "LOW", entry, "Cross-origin script from %s has no integrity attribute", scriptURL.Host)
+		case integrity == "":
+		case entry.SRI == sriMismatch:
+			add("sri-mismatch", "HIGH", entry, "Served script does not match its integrity attribute %q", integrity)
+		case entry.SRI == sriInvalid:
+			add("invalid-sri", "LOW", entry, "Integrity attribute %q has no sha256, sha384 or sha512 hash", integrity)
+		}
+		if integrity != "" && crossOrigin && entry.Attributes.CrossOrigin == "" {
+			add("sri-without-crossorigin", "LOW", entry, "Cross-origin script has an integrity attribute but no crossorigin attribute, so browsers cannot verify it and block it")
+		}
+	}
+	return findings
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestVerifySRI(t *testing.T) {
+	const (
+		body   = "alert(1)"
+		sha256 = "sha256-bhHHL3z2vDgxUt0W3dWQOrprscmda2Y5pLsLg4GF+pI="
+		sha384 = "sha384-HT2E9NfWiuQ/w1PRai+hTyqW16NIoCGA/m8VQDUopfAtcz6YQjtsMmQd5uRbVDpW"
+		sha512 = "sha512-+uuYUxxe7oWIShQrWEmMn/fixz/rxDP4qcAZddXLDM3nN8/tpk1ZC2jXQk6N+mXE65jwfzNVUJL/qjA3y9KbuQ=="
+		bad384 = "sha384-AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+	)
+
+	tests := []struct {
+		integrity string
+		want      string
+	}{
+		{sha256, sriValid},
+		{sha384, sriValid},
+		{sha512, sriValid},
+		{"SHA384-HT2E9NfWiuQ/w1PRai+hTyqW16NIoCGA/m8VQDUopfAtcz6YQjtsMmQd5uRbVDpW", sriValid},
+		{bad384, sriMismatch},
+		// Only the strongest algorithm listed counts
+		{sha256 + " " + bad384, sriMismatch},
+		{bad384 + " " + sha512, sriValid},
+		{sha256 + " " + sha384 + " " + sha512, sriValid},
+		// Any hash of the strongest algorithm may match
+		{bad384 + " " + sha384, sriValid},
+		{sha384 + "?ct=application/javascript", sriValid},
+		{"  " + sha256 + "\n", sriValid},
+		{"md5-7VfB4Ow6vZOFkUb6YjLj0Q== " + sha256, sriValid},
+		{"md5-7VfB4Ow6vZOFkUb6YjLj0Q==", sriInvalid},
+		{"sha384", sriInvalid},
+		{"", sriInvalid},
+	}
+
+	for _, tt := range tests {
+		if got := verifySRI(tt.integrity, body); got != tt.want {
+			t.Errorf("verifySRI(%q) = %s, want %s", tt.integrity, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// version is a parsed library version. It follows semver 2.0.0 — numeric
+// core, pre-release identifiers, ignored build metadata — but accepts any
+// number of core parts ("1.6.0.2", "13.0", "3") and pre-releases glued to
+// the core ("1.0RC2", "1.9.0b1", "3.0.RC3") as found in RetireJS data.
+type version struct {
+	core []int
+	pre  []string
+}
+
+// parseVersion parses a version string, failing only when it does not start
+// with a number
+func parseVersion(s string) (version, error) {
+	var v version
+
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	s, _, _ = strings.Cut(s, "+") // build metadata does not take part in precedence
+
+	rest := s
+	for {
+		end := 0
+		for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+			end++
+		}
+		if end == 0 {
+			break
+		}
+		n, err := strconv.Atoi(rest[:end])
+		if err != nil {
+			return version{}, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		v.core = append(v.core, n)
+		rest = rest[end:]
+
+		// Continue with the next core part only if it is numeric
+		if len(rest) > 1 && rest[0] == '.' && rest[1] >= '0' && rest[1] <= '9' {
+			rest = rest[1:]
+			continue
+		}
+		break
+	}
+	if len(v.core) == 0 {
+		return version{}, fmt.Errorf("invalid version %q", s)
+	}
+
+	rest = strings.TrimLeft(rest, ".-_")
+	if rest != "" {
+		v.pre = strings.FieldsFunc(rest, func(r rune) bool { return r == '.' || r == '-' })
+	}
+	return v, nil
+}
+
+// compare returns -1, 0 or 1 as v is lower than, equal to or higher than o.
+// Missing core parts count as 0, so "1.2" equals "1.2.0".
+func (v version) compare(o version) int {
+	for i := 0; i < len(v.core) || i < len(o.core); i++ {
+		a, b := 0, 0
+		if i < len(v.core) {
+			a = v.core[i]
+		}
+		if i < len(o.core) {
+			b = o.core[i]
+		}
+		if a != b {
+			return cmpInt(a, b)
+		}
+	}
+
+	// A pre-release has lower precedence than the release itself
+	switch {
+	case len(v.pre) == 0 && len(o.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(o.pre) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.pre) && i < len(o.pre); i++ {
+		if c := comparePreRelease(v.pre[i], o.pre[i]); c != 0 {
+			return c
+		}
+	}
+	return cmpInt(len(v.pre), len(o.pre))
+}
+
+// comparePreRelease compares pre-release identifiers: numeric ones
+// numerically and below alphanumeric ones, which compare case-insensitively
+// so that "RC3" and "rc3" are the same release.
+func comparePreRelease(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return cmpInt(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareVersions compares two version strings. Unparsable versions sort
+// below every valid one.
+func compareVersions(a, b string) int {
+	va, errA := parseVersion(a)
+	vb, errB := parseVersion(b)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+	return va.compare(vb)
+}
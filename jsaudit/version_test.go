@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2.3", "1.2.4", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"1.2", "1.2.0", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2.3+build.5", "1.2.3", 0},
+		{"3.0.0-rc.1", "3.0.0", -1},
+		{"1.12.4-aem", "1.12.4", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-alpha.beta", "1.0.0-beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-beta.11", "1.0.0-rc.1", -1},
+		{"1.0.0-rc.1", "1.0.0-rc.1.1", -1},
+		{"15.6.0-canary.59", "15.6.0-canary.60", -1},
+		{"1.6.0.2", "1.6.0", 1},
+		{"1.5.1.2", "1.5.2", -1},
+		{"1.0.0.beta.3", "1.0.0", -1},
+		{"1.0RC2", "1.0", -1},
+		{"1.9.0b1", "1.9.0", -1},
+		{"3.0.RC3", "3.0.rc3", 0},
+		{"1.01", "1.1", 0},
+		{"x", "0", -1},
+	}
+
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := compareVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+// Ranges of the embedded DB that are empty upstream
+var knownEmptyRanges = map[[2]string]bool{
+	{"3", "3.0.RC3"}:          true, // dwr
+	{"1.4.0", "1.4.0-beta.2"}: true, // ember
+}
+
+func TestEmbeddedDBVersions(t *testing.T) {
+	var raw map[string]struct {
+		Vulnerabilities []struct {
+			AtOrAbove string `json:"atOrAbove"`
+			Below     string `json:"below"`
+		} `json:"vulnerabilities"`
+		Extractors struct {
+			Hashes map[string]string `json:"hashes"`
+		} `json:"extractors"`
+	}
+	if err := json.Unmarshal(embeddedRetireDB, &raw); err != nil {
+		t.Fatal(err)
+	}
+
+	for lib, entry := range raw {
+		for _, v := range entry.Vulnerabilities {
+			err := validateRange(v.AtOrAbove, v.Below)
+			if knownEmptyRanges[[2]string{v.AtOrAbove, v.Below}] {
+				if err == nil {
+					t.Errorf("%s: range [%s, %s) expected to be empty", lib, v.AtOrAbove, v.Below)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: %v", lib, err)
+			}
+		}
+		for sum, version := range entry.Extractors.Hashes {
+			if _, err := parseVersion(version); err != nil {
+				t.Errorf("%s: hash %s: %v", lib, sum, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// headerMatch is a response header containing a value ("" for any value)
+type headerMatch struct {
+	name     string
+	contains string
+}
+
+// wafSignature identifies a WAF or bot manager. Headers only tell who serves
+// the response. Challenge markers only appear on challenge and block pages;
+// body markers also appear on regular pages the vendor injects its scripts in.
+type wafSignature struct {
+	vendor    string
+	headers   []headerMatch
+	challenge []string
+	body      []string
+}
+
+var wafSignatures = []wafSignature{
+	{
+		vendor:    "Cloudflare",
+		headers:   []headerMatch{{"Cf-Mitigated", ""}, {"Cf-Ray", ""}, {"Server", "cloudflare"}},
+		challenge: []string{"cf_chl_opt", "<title>Just a moment...</title>", "<title>Attention Required! | Cloudflare</title>"},
+		body:      []string{"/cdn-cgi/challenge-platform/", "cf-chl-"},
+	},
+	{
+		vendor:    "Akamai",
+		headers:   []headerMatch{{"Akamai-Grn", ""}, {"Server", "AkamaiGHost"}, {"Server", "AkamaiNetStorage"}},
+		challenge: []string{"sec-if-cpt-container"},
+		body:      []string{"errors.edgesuite.net", "/_sec/cp_challenge/", "bm-verify"},
+	},
+	{
+		vendor:    "Imperva",
+		headers:   []headerMatch{{"X-Iinfo", ""}, {"X-Cdn", "Imperva"}, {"X-Cdn", "Incapsula"}, {"Set-Cookie", "incap_ses_"}, {"Set-Cookie", "visid_incap_"}},
+		challenge: []string{"Incapsula incident ID", "Request unsuccessful. Incapsula"},
+		body:      []string{"_Incapsula_Resource"},
+	},
+	{
+		vendor:    "DataDome",
+		headers:   []headerMatch{{"X-Datadome", ""}, {"X-Dd-B", ""}, {"Set-Cookie", "datadome="}},
+		challenge: []string{"var dd={"},
+		body:      []string{"captcha-delivery.com"},
+	},
+}
+
+// Statuses WAFs answer with when they refuse or challenge a client
+var blockStatuses = map[int]bool{
+	http.StatusUnauthorized:       true,
+	http.StatusForbidden:          true,
+	http.StatusMethodNotAllowed:   true,
+	http.StatusNotAcceptable:      true,
+	http.StatusTooManyRequests:    true,
+	http.StatusServiceUnavailable: true,
+}
+
+// blockVerdict explains why a response is considered blocked
+type blockVerdict struct {
+	vendor string
+	reason string
+}
+
+// detectBlock tells whether a response is a block or challenge page rather
+// than the requested resource. For scripts (expectHTML false) any HTML body
+// is suspicious; for pages only block statuses and challenge markers count,
+// as body markers also show up on regular pages served through the WAF.
+func detectBlock(res *fetchResult, expectHTML bool) (blockVerdict, bool) {
+	html := sniffHTML(res.body, res.header.Get("Content-Type"))
+	errStatus := res.status >= 400
+
+	if res.header.Get("Cf-Mitigated") == "challenge" {
+		return blockVerdict{vendor: "Cloudflare", reason: "challenge (cf-mitigated header)"}, true
+	}
+	if !html && !errStatus {
+		return blockVerdict{}, false
+	}
+
+	// Markers are only looked for in markup and error pages, so that a
+	// vendor's own script mentioning them is not taken for a block
+	for _, sig := range wafSignatures {
+		markers := sig.challenge
+		if errStatus || !expectHTML {
+			markers = slices.Concat(sig.challenge, sig.body)
+		}
+		for _, marker := range markers {
+			if strings.Contains(res.body, marker) {
+				return blockVerdict{vendor: sig.vendor, reason: "challenge page"}, true
+			}
+		}
+	}
+	// Headers of CDNs are on every response they serve, errors of the origin
+	// included, so they only name the vendor of a block found otherwise
+	vendor := identifyWAF(res.header)
+
+	switch {
+	case errStatus && blockStatuses[res.status]:
+		return blockVerdict{vendor: vendor, reason: http.StatusText(res.status)}, true
+	case html && !expectHTML:
+		return blockVerdict{vendor: vendor, reason: "HTML served instead of JavaScript"}, true
+	}
+	return blockVerdict{}, false
+}
+
+// identifyWAF names the WAF serving a response from its headers
+func identifyWAF(header http.Header) string {
+	for _, sig := range wafSignatures {
+		for _, h := range sig.headers {
+			for _, v := range header.Values(h.name) {
+				if h.contains == "" || strings.Contains(strings.ToLower(v), strings.ToLower(h.contains)) {
+					return sig.vendor
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// sniffHTML reports whether a body is an HTML document. JavaScript never
+// starts with markup, whatever Content-Type the server sends it with.
+func sniffHTML(body, contentType string) bool {
+	trimmed := strings.TrimLeft(strings.TrimPrefix(body, "\ufeff"), " \t\r\n")
+	if !strings.HasPrefix(trimmed, "<") {
+		return false
+	}
+	if ct := strings.ToLower(contentType); strings.Contains(ct, "html") {
+		return true
+	}
+
+	head := strings.ToLower(trimmed)
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	for _, tag := range []string{"<!doctype html", "<html", "<head", "<body", "<title", "<meta", "<div", "<script", "<!--"} {
+		if strings.Contains(head, tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDetectBlock(t *testing.T) {
+	const (
+		// A regular page behind Cloudflare with its bot management snippet
+		cloudflarePage = `<!DOCTYPE html><html><head><title>Shop</title>
+<script src="/static/app.js"></script></head><body><div id="app"></div>
+<script>(function(){var a=document.createElement('script');a.src='/cdn-cgi/challenge-platform/scripts/jsd/main.js';document.head.appendChild(a)})();</script>
+<img class="cf-chl-widget" hidden></body></html>`
+		cloudflareChallenge = `<!DOCTYPE html><html lang="en-US"><head><title>Just a moment...</title></head>
+<body><script>window._cf_chl_opt={cvId: '3',cType: 'managed'};</script>
+<script src="/cdn-cgi/challenge-platform/h/g/orchestrate/chl_page/v1"></script></body></html>`
+		cloudflareBlock = `<!DOCTYPE html><html><head><title>Attention Required! | Cloudflare</title></head><body>Sorry, you have been blocked</body></html>`
+		plainError      = `<html><body><h1>Forbidden</h1></body></html>`
+		script          = `!function(){var e="/cdn-cgi/challenge-platform/";console.log(e)}();`
+	)
+	cloudflare := http.Header{"Server": {"cloudflare"}, "Cf-Ray": {"8a1b2c3d4e5f-CDG"}, "Content-Type": {"text/html; charset=UTF-8"}}
+	html := http.Header{"Content-Type": {"text/html"}}
+	js := http.Header{"Content-Type": {"application/javascript"}}
+
+	tests := []struct {
+		name       string
+		status     int
+		header     http.Header
+		body       string
+		expectHTML bool
+		blocked    bool
+		vendor     string
+	}{
+		{"cloudflare page", 200, cloudflare, cloudflarePage, true, false, ""},
+		{"cloudflare challenge", 200, cloudflare, cloudflareChallenge, true, true, "Cloudflare"},
+		{"cloudflare challenge 403", 403, cloudflare, cloudflareChallenge, true, true, "Cloudflare"},
+		{"cloudflare block", 200, html, cloudflareBlock, true, true, "Cloudflare"},
+		{"cf-mitigated", 200, http.Header{"Cf-Mitigated": {"challenge"}}, "<html></html>", true, true, "Cloudflare"},
+		{"generic marker on error", 503, html, `<html><script src="/cdn-cgi/challenge-platform/x.js"></script></html>`, true, true, "Cloudflare"},
+		{"origin error behind waf", 404, cloudflare, plainError, true, false, ""},
+		{"server error behind waf", 500, cloudflare, plainError, true, false, ""},
+		{"block status behind waf", 403, cloudflare, plainError, true, true, "Cloudflare"},
+		{"block status", 403, html, plainError, true, true, ""},
+		{"not found", 404, html, plainError, true, false, ""},
+		{"cloudflare page as script", 200, cloudflare, cloudflarePage, false, true, "Cloudflare"},
+		{"html as script", 200, html, plainError, false, true, ""},
+		{"script mentioning markers", 200, js, script, false, false, ""},
+	}
+
+	for _, tt := range tests {
+		res := &fetchResult{status: tt.status, body: tt.body, header: tt.header}
+		v, blocked := detectBlock(res, tt.expectHTML)
+		if blocked != tt.blocked || v.vendor != tt.vendor {
+			t.Errorf("%s: detectBlock = %v, %q (%s), want %v, %q", tt.name, blocked, v.vendor, v.reason, tt.blocked, tt.vendor)
+		}
+	}
+}